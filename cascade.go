@@ -0,0 +1,211 @@
+package culebra
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+
+	"github.com/Fuabioo/culebra/internal"
+)
+
+// CascadeRootKey marks a cascading config as the topmost one, stopping the upward search
+const CascadeRootKey = "root"
+
+// CascadeOptions configures the upward search performed by FindCascade and LoadCascade
+type CascadeOptions struct {
+	FileName       string // Name of the project config file, e.g. ".myapp.lua"
+	StartDir       string // Directory the search starts from, defaults to the working directory
+	StopAtRepoRoot bool   // Stop at the first directory containing .git instead of the filesystem root
+}
+
+// FindCascade walks from the start directory up to the filesystem root (or repository root)
+// and returns every config file found, ordered from the outermost to the innermost
+func FindCascade(opts CascadeOptions) ([]string, error) {
+	if opts.FileName == "" {
+		return nil, fmt.Errorf("cascade file name is required")
+	}
+
+	dir := opts.StartDir
+	if dir == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get working directory: %w", err)
+		}
+		dir = wd
+	}
+
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve start directory: %w", err)
+	}
+
+	var found []string
+	for {
+		candidate := filepath.Join(dir, opts.FileName)
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			found = append([]string{candidate}, found...)
+		}
+
+		if opts.StopAtRepoRoot {
+			if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+				break
+			}
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+
+	return found, nil
+}
+
+// LoadCascade loads every config found by FindCascade and layers each file over the one above it.
+// A file setting root = true discards the files above it. A boolean root marker is not part of the
+// result, other values of the root key are kept as regular config.
+// Globals and conversion settings in cfg apply to every file; its FilePath is ignored. The merged
+// result, not each file, is validated against Config.Schema and the schemas declared with culebra.schema.
+func LoadCascade(cfg Config, opts CascadeOptions) (map[string]any, []string, error) {
+	data, layers, err := loadCascade(cfg, opts)
+	return data, layerFiles(layers), err
+}
+
+// loadCascade implements LoadCascade and also returns the used layers, outermost first
+func loadCascade(cfg Config, opts CascadeOptions) (map[string]any, []cascadeLayer, error) {
+	files, err := FindCascade(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(files) == 0 {
+		return nil, nil, fmt.Errorf("%w: no %s found", ErrConfigNotFound, opts.FileName)
+	}
+
+	// Load from the innermost file outwards until a root marker stops the cascade
+	var layers []cascadeLayer
+	for i := len(files) - 1; i >= 0; i-- {
+		layer, err := loadLayer(cfg, files[i])
+		if err != nil {
+			return nil, nil, err
+		}
+
+		layers = append([]cascadeLayer{layer}, layers...)
+
+		if layer.isRoot {
+			break
+		}
+	}
+
+	result, err := cfg.mergeLayers(layers)
+	if err != nil {
+		return nil, nil, err
+	}
+	return result, layers, nil
+}
+
+// cascadeLayer is one loaded cascade file, not yet validated
+type cascadeLayer struct {
+	file     string
+	data     map[string]any
+	declared *Schema           // Declared with culebra.schema, or nil
+	origins  map[string]Origin // Where the keys were assigned, recorded with Config.trackOrigins
+	isRoot   bool
+}
+
+// loadLayer loads one cascade file and reports whether it is marked as the root. Only a boolean
+// root key is a marker and removed from the data. Schemas apply to the merged layers, so the
+// layer is converted without being validated.
+func loadLayer(cfg Config, file string) (cascadeLayer, error) {
+	cfg.FilePath = file
+	cfg.Schema = nil
+	L, table, err := evaluate(cfg)
+	if err != nil {
+		return cascadeLayer{}, fmt.Errorf("failed to load %s: %w", file, err)
+	}
+	defer L.Close()
+
+	data, err := internal.ConvertTableWithState(L, table, cfg.convertOptions())
+	if err != nil {
+		return cascadeLayer{}, fmt.Errorf("failed to load %s: failed to convert lua config: %w", file, err)
+	}
+
+	isRoot, isMarker := data[CascadeRootKey].(bool)
+	if isMarker {
+		delete(data, CascadeRootKey)
+	}
+	layer := cascadeLayer{file: file, data: data, declared: declaredSchema(L), isRoot: isRoot}
+	if cfg.trackOrigins {
+		layer.origins = evaluationOf(L).keyOrigins(L, table)
+	}
+	return layer, nil
+}
+
+// mergeLayers merges cascade layers, outermost first, and validates the result against
+// Config.Schema and the schemas the layers declared with culebra.schema. Violations are located
+// in the innermost layer assigning the offending key.
+func (cfg Config) mergeLayers(layers []cascadeLayer) (map[string]any, error) {
+	result := make(map[string]any)
+	for _, layer := range layers {
+		result = mergeConfig(result, layer.data)
+	}
+
+	schemas, err := cfg.schemas(declaredSchemas(layers)...)
+	if err != nil || len(schemas) == 0 {
+		return result, err
+	}
+
+	err = validateAll(result, schemas...)
+	if errs, ok := err.(ValidationErrors); ok {
+		origins := make(map[string]Origin)
+		for _, layer := range layers {
+			maps.Copy(origins, fileOrigins(layer.file))
+		}
+		locateErrors(errs, origins)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid lua config: %w", err)
+	}
+	return result, nil
+}
+
+// layerFiles returns the files of the layers, outermost first
+func layerFiles(layers []cascadeLayer) []string {
+	var files []string
+	for _, layer := range layers {
+		files = append(files, layer.file)
+	}
+	return files
+}
+
+// declaredSchemas returns the schemas declared with culebra.schema by the layers, outermost first
+func declaredSchemas(layers []cascadeLayer) []*Schema {
+	var declared []*Schema
+	for _, layer := range layers {
+		if layer.declared != nil {
+			declared = append(declared, layer.declared)
+		}
+	}
+	return declared
+}
+
+// mergeConfig deep merges src over a copy of dst, with values from src taking precedence
+func mergeConfig(dst, src map[string]any) map[string]any {
+	result := make(map[string]any, len(dst)+len(src))
+	for key, value := range dst {
+		result[key] = value
+	}
+
+	for key, value := range src {
+		srcMap, srcIsMap := value.(map[string]any)
+		dstMap, dstIsMap := result[key].(map[string]any)
+		if srcIsMap && dstIsMap {
+			result[key] = mergeConfig(dstMap, srcMap)
+			continue
+		}
+		result[key] = value
+	}
+
+	return result
+}
@@ -0,0 +1,189 @@
+package culebra
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const cascadeFileName = ".culebra-cascade-test.lua"
+
+func writeCascadeFile(t *testing.T, dir, content string) string {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, cascadeFileName)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+	return path
+}
+
+func TestLoadCascade(t *testing.T) {
+	top := t.TempDir()
+	project := filepath.Join(top, "project")
+	module := filepath.Join(project, "module")
+
+	topFile := writeCascadeFile(t, top, `
+owner = "top"
+server = { host = "top.example.com", port = 80 }
+`)
+	projectFile := writeCascadeFile(t, project, `
+server = { port = 8080 }
+`)
+	moduleFile := writeCascadeFile(t, module, `
+name = "module"
+`)
+
+	data, files, err := LoadCascade(Config{}, CascadeOptions{FileName: cascadeFileName, StartDir: module})
+	if err != nil {
+		t.Fatalf("LoadCascade failed: %v", err)
+	}
+
+	if expected := []string{topFile, projectFile, moduleFile}; !reflect.DeepEqual(files, expected) {
+		t.Errorf("expected files %v, got %v", expected, files)
+	}
+
+	expected := map[string]any{
+		"owner":  "top",
+		"name":   "module",
+		"server": map[string]any{"host": "top.example.com", "port": float64(8080)},
+	}
+	if !reflect.DeepEqual(data, expected) {
+		t.Errorf("expected %v, got %v", expected, data)
+	}
+}
+
+func TestLoadCascadeRootMarker(t *testing.T) {
+	top := t.TempDir()
+	project := filepath.Join(top, "project")
+	module := filepath.Join(project, "module")
+
+	writeCascadeFile(t, top, `owner = "top"`)
+	projectFile := writeCascadeFile(t, project, `
+root = true
+server = { port = 8080 }
+`)
+	moduleFile := writeCascadeFile(t, module, `name = "module"`)
+
+	data, files, err := LoadCascade(Config{}, CascadeOptions{FileName: cascadeFileName, StartDir: module})
+	if err != nil {
+		t.Fatalf("LoadCascade failed: %v", err)
+	}
+
+	if expected := []string{projectFile, moduleFile}; !reflect.DeepEqual(files, expected) {
+		t.Errorf("expected files %v, got %v", expected, files)
+	}
+	if _, ok := data["owner"]; ok {
+		t.Error("expected files above the root marker to be ignored")
+	}
+	if _, ok := data[CascadeRootKey]; ok {
+		t.Error("expected root marker to be removed from the result")
+	}
+}
+
+func TestLoadCascadeValidatesMergedLayers(t *testing.T) {
+	top := t.TempDir()
+	project := filepath.Join(top, "project")
+
+	writeCascadeFile(t, top, "port = 8080\n")
+	projectFile := writeCascadeFile(t, project, `culebra.schema{ name = culebra.string{ required = true } }
+name = "app"
+level = 3
+`)
+
+	schema := &Schema{Type: TypeObject, Fields: map[string]*Schema{
+		"port":  {Type: TypeInteger, Required: true},
+		"level": {Type: TypeString},
+	}}
+	_, _, err := LoadCascade(Config{Schema: schema}, CascadeOptions{FileName: cascadeFileName, StartDir: project})
+	var errs ValidationErrors
+	if !errors.As(err, &errs) || len(errs) != 1 {
+		t.Fatalf("Expected one validation error for the merged layers, got %v", err)
+	}
+	if errs[0].Path != "level" || errs[0].File != projectFile || errs[0].Line != 3 {
+		t.Errorf("Expected violation at %s:3, got %+v", projectFile, errs[0])
+	}
+
+	if _, _, err := LoadCascade(Config{Schema: schema}, CascadeOptions{FileName: cascadeFileName, StartDir: top}); err != nil {
+		t.Errorf("Expected the outer layer alone to be valid, got %v", err)
+	}
+}
+
+func TestFindCascadeStopAtRepoRoot(t *testing.T) {
+	top := t.TempDir()
+	repo := filepath.Join(top, "repo")
+	module := filepath.Join(repo, "module")
+
+	writeCascadeFile(t, top, `owner = "top"`)
+	repoFile := writeCascadeFile(t, repo, `name = "repo"`)
+	if err := os.MkdirAll(filepath.Join(repo, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(module, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := FindCascade(CascadeOptions{FileName: cascadeFileName, StartDir: module, StopAtRepoRoot: true})
+	if err != nil {
+		t.Fatalf("FindCascade failed: %v", err)
+	}
+	if expected := []string{repoFile}; !reflect.DeepEqual(files, expected) {
+		t.Errorf("expected files %v, got %v", expected, files)
+	}
+}
+
+func TestLoadCascadeNotFound(t *testing.T) {
+	_, _, err := LoadCascade(Config{}, CascadeOptions{FileName: cascadeFileName, StartDir: t.TempDir()})
+	if !errors.Is(err, ErrConfigNotFound) {
+		t.Errorf("expected ErrConfigNotFound, got %v", err)
+	}
+}
+
+func TestLoadCascadeRootValue(t *testing.T) {
+	dir := t.TempDir()
+	writeCascadeFile(t, dir, `root = "/srv"`)
+
+	data, _, err := LoadCascade(Config{}, CascadeOptions{FileName: cascadeFileName, StartDir: dir})
+	if err != nil {
+		t.Fatalf("LoadCascade failed: %v", err)
+	}
+	if data[CascadeRootKey] != "/srv" {
+		t.Errorf("expected non-boolean root key to be kept, got %v", data[CascadeRootKey])
+	}
+}
+
+func TestLoadCascadeConfigRecordsConfig(t *testing.T) {
+	top := t.TempDir()
+	module := filepath.Join(top, "module")
+	writeCascadeFile(t, top, `owner = "top"`)
+	moduleFile := writeCascadeFile(t, module, `name = "module"`)
+	t.Chdir(module)
+
+	defer viper.Reset()
+	defer func() {
+		luaConfigUsedMu.Lock()
+		luaConfigUsed = nil
+		luaConfigUsedMu.Unlock()
+	}()
+
+	cmd := &cobra.Command{Use: "culebra-cascade-test"}
+	loadCascadeConfig(cmd, CobraOptions{}, nil)
+
+	cfg, ok := luaConfigUsedByCobra()
+	if !ok {
+		t.Fatal("expected cascade load to record the config")
+	}
+	if cfg.FilePath != moduleFile || len(cfg.cascade) != 2 {
+		t.Errorf("expected innermost file and both layers, got %s and %v", cfg.FilePath, cfg.cascade)
+	}
+	if viper.GetString("owner") != "top" {
+		t.Errorf("expected owner from the outer layer, got %q", viper.GetString("owner"))
+	}
+}
@@ -0,0 +1,111 @@
+package culebra
+
+import (
+	"bytes"
+	"fmt"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// checksKey is the registry key holding the findings of culebra.check and culebra.warn
+const checksKey = "culebra.checks"
+
+// Finding is a failed culebra.check or a culebra.warn message with the location of the call
+type Finding struct {
+	Message string
+	File    string
+	Line    int
+}
+
+func (f Finding) String() string {
+	if f.Line > 0 {
+		return fmt.Sprintf("%s:%d: %s", f.File, f.Line, f.Message)
+	}
+	return f.Message
+}
+
+// CheckError reports every failed culebra.check of a config, together with its warnings
+type CheckError struct {
+	Failures []Finding
+	Warnings []Finding
+}
+
+func (e *CheckError) Error() string {
+	if len(e.Failures) == 1 {
+		return "config check failed: " + e.Failures[0].String()
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%d config checks failed:", len(e.Failures))
+	for _, failure := range e.Failures {
+		buf.WriteString("\n  " + failure.String())
+	}
+	return buf.String()
+}
+
+// checkResults collects the findings of one evaluation
+type checkResults struct {
+	failures []Finding
+	warnings []Finding
+}
+
+// openChecks adds check and warn to the culebra module. Unlike assert, culebra.check(cond, msg)
+// records a failure and lets the config continue, so every problem is reported at once:
+//
+//	culebra.check(database.host, "database.host is required")
+//	culebra.check(database.port > 0, "database.port must be positive")
+//	culebra.warn("debug mode is enabled")
+func openChecks(L *lua.LState, module *lua.LTable) {
+	results := &checkResults{}
+	ud := L.NewUserData()
+	ud.Value = results
+	L.SetField(L.Get(lua.RegistryIndex), checksKey, ud)
+
+	module.RawSetString("check", L.NewFunction(func(L *lua.LState) int {
+		condition := L.CheckAny(1)
+		if !lua.LVAsBool(condition) {
+			results.failures = append(results.failures, newFinding(L, L.OptString(2, "check failed")))
+		}
+		L.Push(condition)
+		return 1
+	}))
+	module.RawSetString("warn", L.NewFunction(func(L *lua.LState) int {
+		results.warnings = append(results.warnings, newFinding(L, L.CheckString(1)))
+		return 0
+	}))
+}
+
+// newFinding records message with the file and line of the Lua code calling the current function
+func newFinding(L *lua.LState, message string) Finding {
+	finding := Finding{Message: message}
+	if dbg, ok := L.GetStack(1); ok {
+		if _, err := L.GetInfo("Sl", dbg, lua.LNil); err == nil {
+			finding.File, finding.Line = dbg.Source, dbg.CurrentLine
+		}
+	}
+	return finding
+}
+
+// checkFindings returns the findings collected in a state
+func checkFindings(L *lua.LState) *checkResults {
+	if ud, ok := L.GetField(L.Get(lua.RegistryIndex), checksKey).(*lua.LUserData); ok {
+		if results, ok := ud.Value.(*checkResults); ok {
+			return results
+		}
+	}
+	return &checkResults{}
+}
+
+// reportChecks returns a CheckError when a check failed and otherwise passes the warnings to
+// Config.OnWarning
+func reportChecks(L *lua.LState, cfg Config) error {
+	results := checkFindings(L)
+	if len(results.failures) > 0 {
+		return &CheckError{Failures: results.failures, Warnings: results.warnings}
+	}
+	if cfg.OnWarning != nil {
+		for _, warning := range results.warnings {
+			cfg.OnWarning(warning)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package culebra
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCulebraCheck(t *testing.T) {
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "test.lua")
+
+	configContent := `database = { port = 0 }
+culebra.check(database.host, "database.host is required")
+culebra.check(database.port > 0, "database.port must be positive")
+culebra.warn("debug mode is enabled")
+local port = culebra.check(database.port, "unused")
+`
+
+	if err := os.WriteFile(configFile, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	_, err := Load(Config{FilePath: configFile})
+	var checkErr *CheckError
+	if !errors.As(err, &checkErr) {
+		t.Fatalf("Expected CheckError, got %v", err)
+	}
+
+	expected := []Finding{
+		{Message: "database.host is required", File: configFile, Line: 2},
+		{Message: "database.port must be positive", File: configFile, Line: 3},
+	}
+	if !reflect.DeepEqual(checkErr.Failures, expected) {
+		t.Errorf("Expected failures %v, got %v", expected, checkErr.Failures)
+	}
+	if len(checkErr.Warnings) != 1 || checkErr.Warnings[0].Line != 4 {
+		t.Errorf("Expected one warning at line 4, got %v", checkErr.Warnings)
+	}
+}
+
+func TestCulebraWarn(t *testing.T) {
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "test.lua")
+
+	configContent := `debug_mode = true
+if debug_mode then
+    culebra.warn("debug mode is enabled")
+end
+culebra.check(true, "never reported")
+`
+
+	if err := os.WriteFile(configFile, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	var warnings []Finding
+	result, err := Load(Config{FilePath: configFile, OnWarning: func(w Finding) { warnings = append(warnings, w) }})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if result["debug_mode"] != true {
+		t.Errorf("Expected config to load, got %v", result)
+	}
+	if len(warnings) != 1 || warnings[0].String() != configFile+":3: debug mode is enabled" {
+		t.Errorf("Expected warning with its location, got %v", warnings)
+	}
+}
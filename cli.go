@@ -0,0 +1,111 @@
+package culebra
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/Fuabioo/culebra/internal"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// CLIGlobal is the name of the read-only global describing the invoked command
+const CLIGlobal = "cli"
+
+// invokedCommand returns the command cobra is executing, identified by its parsed flag set. Commands
+// that disable flag parsing, such as __complete and Lua aliases, are found by the context Cobra
+// hands to the executed command instead, and the description then holds the flag defaults.
+func invokedCommand(root *cobra.Command) *cobra.Command {
+	if parsed := parsedCommand(root); parsed != nil {
+		return parsed
+	}
+	if executing := contextCommand(root); executing != nil {
+		return executing
+	}
+	return root
+}
+
+// parsedCommand returns the command whose flags were parsed, or nil
+func parsedCommand(root *cobra.Command) *cobra.Command {
+	for _, child := range root.Commands() {
+		if found := parsedCommand(child); found != nil {
+			return found
+		}
+	}
+	if root.Flags().Parsed() {
+		return root
+	}
+	return nil
+}
+
+// contextCommand returns the deepest command holding a context, which Cobra sets on the root
+// and on the command it executes, or nil
+func contextCommand(root *cobra.Command) *cobra.Command {
+	for _, child := range root.Commands() {
+		if found := contextCommand(child); found != nil {
+			return found
+		}
+	}
+	if root.Context() != nil {
+		return root
+	}
+	return nil
+}
+
+// cliGlobals describes a parsed command as a read-only value for the Lua config:
+// the command path, typed flag values, which flags were set explicitly and positional args
+func cliGlobals(cmd *cobra.Command) internal.ReadOnly {
+	flags := make(map[string]any)
+	changed := make(map[string]any)
+
+	cmd.Flags().VisitAll(func(flag *pflag.Flag) {
+		flags[flag.Name] = flagValue(flag)
+		changed[flag.Name] = flag.Changed
+	})
+
+	args := make([]any, 0, len(cmd.Flags().Args()))
+	for _, arg := range cmd.Flags().Args() {
+		args = append(args, arg)
+	}
+
+	return internal.ReadOnly{Value: map[string]any{
+		"command": cmd.CommandPath(),
+		"name":    cmd.Name(),
+		"flags":   flags,
+		"changed": changed,
+		"args":    args,
+	}}
+}
+
+// flagValue converts a pflag value into the Go type matching its declared pflag type
+func flagValue(flag *pflag.Flag) any {
+	flagType := flag.Value.Type()
+
+	if slice, ok := flag.Value.(pflag.SliceValue); ok {
+		elementType := strings.TrimSuffix(strings.TrimSuffix(flagType, "Slice"), "Array")
+		values := make([]any, 0, len(slice.GetSlice()))
+		for _, value := range slice.GetSlice() {
+			values = append(values, typedFlagString(elementType, value))
+		}
+		return values
+	}
+
+	return typedFlagString(flagType, flag.Value.String())
+}
+
+// typedFlagString parses the string form of a flag value according to its pflag type name
+func typedFlagString(flagType, value string) any {
+	switch flagType {
+	case "bool":
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	case "int", "int8", "int16", "int32", "int64", "count",
+		"uint", "uint8", "uint16", "uint32", "uint64",
+		"float32", "float64":
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	}
+	return value
+}
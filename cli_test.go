@@ -0,0 +1,173 @@
+package culebra
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func TestCLIGlobals(t *testing.T) {
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "test.lua")
+
+	configContent := `
+local flag_names = {}
+for name in pairs(cli.flags) do
+    table.insert(flag_names, name)
+end
+table.sort(flag_names)
+
+local args = {}
+for _, arg in ipairs(cli.args) do
+    table.insert(args, arg)
+end
+
+return {
+    command = cli.command,
+    env = cli.flags.env,
+    verbose = cli.flags.verbose,
+    replicas = cli.flags.replicas,
+    tags = cli.flags.tags,
+    env_set = cli.changed.env,
+    replicas_set = cli.changed.replicas,
+    flag_names = table.concat(flag_names, ","),
+    args = args,
+    arg_count = #cli.args,
+    joined_args = table.concat(cli.args, " "),
+}
+`
+
+	if err := os.WriteFile(configFile, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	var result map[string]any
+	var loadErr error
+
+	root := &cobra.Command{Use: "myapp"}
+	deploy := &cobra.Command{
+		Use: "deploy",
+		Run: func(cmd *cobra.Command, args []string) {
+			globals := map[string]any{CLIGlobal: cliGlobals(invokedCommand(root))}
+			result, loadErr = Load(Config{FilePath: configFile, Globals: globals, ConvertArrays: true})
+		},
+	}
+	deploy.Flags().String("env", "dev", "target environment")
+	deploy.Flags().Bool("verbose", false, "verbose output")
+	deploy.Flags().Int("replicas", 1, "replica count")
+	deploy.Flags().StringSlice("tags", nil, "tags")
+	root.AddCommand(deploy)
+
+	root.SetArgs([]string{"deploy", "--env", "staging", "--verbose", "--tags", "a,b", "web", "api"})
+	if err := root.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if loadErr != nil {
+		t.Fatalf("Load failed: %v", loadErr)
+	}
+
+	expected := map[string]any{
+		"command":      "myapp deploy",
+		"env":          "staging",
+		"verbose":      true,
+		"replicas":     float64(1),
+		"tags":         []any{"a", "b"},
+		"env_set":      true,
+		"replicas_set": false,
+		"flag_names":   "env,help,replicas,tags,verbose",
+		"args":         []any{"web", "api"},
+		"arg_count":    float64(2),
+		"joined_args":  "web api",
+	}
+
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("expected %v, got %v", expected, result)
+	}
+}
+
+func TestCLIGlobalsReadOnly(t *testing.T) {
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "test.lua")
+
+	if err := os.WriteFile(configFile, []byte(`cli.flags.env = "prod"`), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	var loadErr error
+	root := &cobra.Command{
+		Use: "myapp",
+		Run: func(cmd *cobra.Command, args []string) {
+			globals := map[string]any{CLIGlobal: cliGlobals(cmd)}
+			_, loadErr = Load(Config{FilePath: configFile, Globals: globals})
+		},
+	}
+	root.Flags().String("env", "dev", "target environment")
+	root.SetArgs([]string{})
+	if err := root.Execute(); err != nil {
+		t.Fatal(err)
+	}
+
+	if loadErr == nil {
+		t.Fatal("Expected error when modifying cli table")
+	}
+	if !strings.Contains(loadErr.Error(), "cli.flags.env") {
+		t.Errorf("Expected error to name the key, got %v", loadErr)
+	}
+}
+
+func TestInvokedCommandWithoutParsedFlags(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "test.lua")
+	if err := os.WriteFile(configFile, []byte(`environment = cli.flags.env command = cli.command`), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	var result map[string]any
+	var loadErr error
+	root := &cobra.Command{Use: "myapp"}
+	prod := &cobra.Command{
+		Use:                "prod",
+		DisableFlagParsing: true,
+		Run: func(cmd *cobra.Command, args []string) {
+			globals := map[string]any{CLIGlobal: cliGlobals(invokedCommand(root))}
+			result, loadErr = Load(Config{FilePath: configFile, Globals: globals})
+		},
+	}
+	prod.Flags().String("env", "dev", "target environment")
+	root.AddCommand(prod)
+
+	root.SetArgs([]string{"prod", "y"})
+	if err := root.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if loadErr != nil {
+		t.Fatalf("Load failed: %v", loadErr)
+	}
+	if expected := map[string]any{"environment": "dev", "command": "myapp prod"}; !reflect.DeepEqual(result, expected) {
+		t.Errorf("expected %v, got %v", expected, result)
+	}
+}
+
+func TestTryLuaConfigReportsLoadErrors(t *testing.T) {
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "config.lua")
+	if err := os.WriteFile(configFile, []byte(`environment = cli.flags.env`), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	cmd := &cobra.Command{Use: "myapp"}
+	var errOut strings.Builder
+	cmd.SetErr(&errOut)
+	if !tryLuaConfig(cmd, configFile, nil) {
+		t.Fatal("Expected an existing config to be reported as found")
+	}
+	if !strings.Contains(errOut.String(), "Error loading lua config file") {
+		t.Errorf("Expected the load error to be printed, got %q", errOut.String())
+	}
+	if tryLuaConfig(cmd, filepath.Join(tmpDir, "missing.lua"), nil) {
+		t.Error("Expected a missing config not to be reported as found")
+	}
+}
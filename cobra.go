@@ -1,52 +1,211 @@
 package culebra
 
 import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
 	"path/filepath"
 	"reflect"
 	"strings"
+	"sync"
 
+	"github.com/Fuabioo/culebra/internal"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
+// Discovery selects where UseWithCobra looks for a Lua config when --config is not given
+type Discovery int
+
+const (
+	// DiscoverViper searches Viper's configured config name and paths (the default)
+	DiscoverViper Discovery = iota
+	// DiscoverXDG searches the XDG base directories, the home directory and /etc, see ConfigCandidates
+	DiscoverXDG
+	// DiscoverUpward layers every .<app>.lua found from the working directory upwards, see LoadCascade
+	DiscoverUpward
+)
+
+// CobraOptions customizes the Lua config support added by UseWithCobraOptions
+type CobraOptions struct {
+	Discovery  Discovery
+	AppName    string // Directory name used by discovery, defaults to the command name
+	ConfigName string // Config file name without extension used by discovery, defaults to "config"
+	Required   bool   // Report an error listing the checked candidates when discovery finds nothing
+
+	StopAtRepoRoot bool // Stop the upward search at the repository root
+}
+
+var (
+	luaConfigUsedMu sync.RWMutex
+	luaConfigUsed   *Config
+)
+
+// setLuaConfigUsed records the Lua config bound to Viper by the Cobra integration
+func setLuaConfigUsed(cfg Config) {
+	luaConfigUsedMu.Lock()
+	defer luaConfigUsedMu.Unlock()
+	luaConfigUsed = &cfg
+}
+
+// luaConfigUsedByCobra returns the Lua config bound to Viper by the Cobra integration, if any.
+// Its warnings were printed when it was bound, so the copy has no OnWarning hook and evaluating
+// it again does not repeat them.
+func luaConfigUsedByCobra() (Config, bool) {
+	luaConfigUsedMu.RLock()
+	defer luaConfigUsedMu.RUnlock()
+	if luaConfigUsed == nil {
+		return Config{}, false
+	}
+	cfg := *luaConfigUsed
+	cfg.OnWarning = nil
+	return cfg, true
+}
+
 // UseWithCobra adds Lua config support to a Cobra command with automatic detection
 func UseWithCobra(cmd *cobra.Command) {
+	UseWithCobraOptions(cmd, CobraOptions{})
+}
+
+// UseWithCobraOptions adds Lua config support to a Cobra command using the given discovery options
+func UseWithCobraOptions(cmd *cobra.Command, opts CobraOptions) {
 	var configFile string
+	var overrides []string
 
 	cmd.PersistentFlags().StringVar(&configFile, "config", "", "config file (supports .lua, .yml, .json)")
+	cmd.PersistentFlags().StringArrayVar(&overrides, "set", nil, "set a value visible to the lua config before it runs (key=value, repeatable)")
+	if err := cmd.RegisterFlagCompletionFunc("set", completeSetFlag); err != nil {
+		cmd.PrintErrf("Error registering --set completion: %v\n", err)
+	}
 
 	cobra.OnInitialize(func() {
-		// If config file is explicitly provided, use it
-		if configFile != "" {
-			loadConfig(cmd, configFile)
+		values, err := ParseOverrides(overrides)
+		if err != nil {
+			cmd.PrintErrf("Error parsing --set: %v\n", err)
 			return
 		}
 
-		// Check if Viper has a config file path configured
-		if viperConfigFile := viper.ConfigFileUsed(); viperConfigFile != "" {
-			loadConfig(cmd, viperConfigFile)
-			return
+		// Overrides are injected as globals so the script can derive values from them
+		globals := maps.Clone(values)
+
+		// Expose the invoked command so the script can branch on flags and args
+		globals[CLIGlobal] = cliGlobals(invokedCommand(cmd))
+
+		// Without a Lua config no script sees the globals, so the overrides are set in Viper directly
+		if !loadCobraConfig(cmd, opts, configFile, globals) {
+			setOverrides(viper.GetViper(), values, "")
 		}
+	})
+}
 
-		// Check if SetConfigName was called - enable autoload for Lua files
-		configName := getViperConfigName()
-		if configName != "" {
-			configPaths := getViperConfigPaths()
+// loadCobraConfig binds the config given with --config, configured in Viper or found by discovery,
+// and reports whether it is a Lua config
+func loadCobraConfig(cmd *cobra.Command, opts CobraOptions, configFile string, globals map[string]any) bool {
+	// If config file is explicitly provided, use it
+	if configFile != "" {
+		return loadConfig(cmd, configFile, globals)
+	}
 
-			// If no paths are configured, default to current directory
-			if len(configPaths) == 0 {
-				configPaths = []string{"."}
-			}
+	// Check if Viper has a config file path configured
+	if viperConfigFile := viper.ConfigFileUsed(); viperConfigFile != "" {
+		return loadConfig(cmd, viperConfigFile, globals)
+	}
 
-			// Try to find .lua version in all configured paths
-			for _, path := range configPaths {
-				luaFile := filepath.Join(path, configName+".lua")
-				if tryLuaConfig(cmd, luaFile) {
-					return
-				}
+	switch opts.Discovery {
+	case DiscoverXDG:
+		return discoverConfig(cmd, opts, globals)
+	case DiscoverUpward:
+		return loadCascadeConfig(cmd, opts, globals)
+	}
+
+	// Check if SetConfigName was called - enable autoload for Lua files
+	configName := getViperConfigName()
+	if configName != "" {
+		configPaths := getViperConfigPaths()
+
+		// If no paths are configured, default to current directory
+		if len(configPaths) == 0 {
+			configPaths = []string{"."}
+		}
+
+		// Try to find .lua version in all configured paths
+		for _, path := range configPaths {
+			luaFile := filepath.Join(path, configName+".lua")
+			if tryLuaConfig(cmd, luaFile, globals) {
+				return true
 			}
 		}
-	})
+	}
+	return false
+}
+
+// setOverrides sets the leaves of parsed --set overrides in Viper under their dotted keys, so
+// they do not replace the sections of the config they are nested in
+func setOverrides(v *viper.Viper, values map[string]any, prefix string) {
+	for key, value := range values {
+		if nested, ok := value.(map[string]any); ok {
+			setOverrides(v, nested, prefix+key+".")
+			continue
+		}
+		v.Set(prefix+key, value)
+	}
+}
+
+// discoverConfig loads the first config found in the standard locations for the app and reports
+// whether it is a Lua config
+func discoverConfig(cmd *cobra.Command, opts CobraOptions, globals map[string]any) bool {
+	appName := opts.AppName
+	if appName == "" {
+		appName = cmd.Name()
+	}
+	configName := opts.ConfigName
+	if configName == "" {
+		configName = "config"
+	}
+
+	configFile, err := FindConfig(ConfigCandidates(appName, configName))
+	if err != nil {
+		if opts.Required {
+			cmd.PrintErrf("Error finding config file: %v\n", err)
+		}
+		return false
+	}
+
+	return loadConfig(cmd, configFile, globals)
+}
+
+// loadCascadeConfig binds the layered project configs found above the working directory to Viper
+// and reports whether any was found
+func loadCascadeConfig(cmd *cobra.Command, opts CobraOptions, globals map[string]any) bool {
+	appName := opts.AppName
+	if appName == "" {
+		appName = cmd.Name()
+	}
+
+	cascade := CascadeOptions{FileName: "." + appName + ".lua", StopAtRepoRoot: opts.StopAtRepoRoot}
+	cfg := Config{Globals: globals, IncludeGlobals: true, OnWarning: printWarning(cmd), trackOrigins: true}
+	data, layers, err := loadCascade(cfg, cascade)
+	if err != nil {
+		if opts.Required || !errors.Is(err, ErrConfigNotFound) {
+			cmd.PrintErrf("Error loading config file: %v\n", err)
+		}
+		return !errors.Is(err, ErrConfigNotFound)
+	}
+
+	for key, value := range data {
+		viper.Set(key, value)
+	}
+
+	cfg.cascade = layerFiles(layers)
+	cfg.FilePath = cfg.cascade[len(cfg.cascade)-1]
+	cfg.declared = declaredSchemas(layers)
+	cfg.bound = data
+	for _, layer := range layers {
+		cfg.boundOrigins = append(cfg.boundOrigins, layer.origins)
+	}
+	setLuaConfigUsed(cfg)
+	return true
 }
 
 // getViperConfigName uses reflection to get the config name from viper
@@ -80,7 +239,7 @@ func AutoLoadLua(cmd *cobra.Command) {
 	cobra.OnInitialize(func() {
 		// Check if Viper has a config file path configured
 		if viperConfigFile := viper.ConfigFileUsed(); viperConfigFile != "" {
-			tryLuaConfig(cmd, viperConfigFile)
+			tryLuaConfig(cmd, viperConfigFile, nil)
 			return
 		}
 
@@ -89,44 +248,89 @@ func AutoLoadLua(cmd *cobra.Command) {
 		if configName == "" {
 			// Try common config names if none set
 			for _, name := range []string{"config", cmd.Name()} {
-				if tryLuaConfig(cmd, name) {
+				if tryLuaConfig(cmd, name, nil) {
 					return
 				}
 			}
 		} else {
-			tryLuaConfig(cmd, configName)
+			tryLuaConfig(cmd, configName, nil)
 		}
 	})
 }
 
-func loadConfig(cmd *cobra.Command, configFile string) {
+// printWarning returns a Config.OnWarning hook printing culebra.warn messages to the command's stderr
+func printWarning(cmd *cobra.Command) func(Finding) {
+	return func(warning Finding) {
+		cmd.PrintErrf("Warning: %s\n", warning)
+	}
+}
+
+// loadConfig binds a config file to Viper and reports whether it is a Lua config
+func loadConfig(cmd *cobra.Command, configFile string, globals map[string]any) bool {
 	ext := strings.ToLower(filepath.Ext(configFile))
 
 	if ext == ".lua" {
-		cfg := Config{FilePath: configFile}
-		if err := BindToViper(cfg, viper.GetViper()); err != nil {
-			cmd.PrintErrf("Error loading config file %s: %v\n", configFile, err)
-		}
-	} else {
-		// Let Viper handle non-Lua config files
-		viper.SetConfigFile(configFile)
-		if err := viper.ReadInConfig(); err != nil {
+		cfg := Config{FilePath: configFile, Globals: globals, IncludeGlobals: true, OnWarning: printWarning(cmd)}
+		if err := bindLuaConfig(cfg); err != nil {
 			cmd.PrintErrf("Error loading config file %s: %v\n", configFile, err)
 		}
+		return true
+	}
+
+	// Let Viper handle non-Lua config files
+	viper.SetConfigFile(configFile)
+	if err := viper.ReadInConfig(); err != nil {
+		cmd.PrintErrf("Error loading config file %s: %v\n", configFile, err)
 	}
+	return false
 }
 
-func tryLuaConfig(cmd *cobra.Command, basePath string) bool {
+// tryLuaConfig binds the Lua version of basePath and reports whether it exists. Errors of a file
+// that exists but fails to load are printed rather than skipped.
+func tryLuaConfig(cmd *cobra.Command, basePath string, globals map[string]any) bool {
 	// Remove extension if present
 	nameWithoutExt := strings.TrimSuffix(basePath, filepath.Ext(basePath))
 	luaFile := nameWithoutExt + ".lua"
 
-	cfg := Config{FilePath: luaFile}
-	if _, err := Load(cfg); err == nil {
-		if err := BindToViper(cfg, viper.GetViper()); err != nil {
-			cmd.PrintErrf("Error loading lua config file %s: %v\n", luaFile, err)
-		}
-		return true
+	if _, err := os.Stat(luaFile); err != nil {
+		return false
 	}
-	return false
+
+	cfg := Config{FilePath: luaFile, Globals: globals, IncludeGlobals: true, OnWarning: printWarning(cmd)}
+	if err := bindLuaConfig(cfg); err != nil {
+		cmd.PrintErrf("Error loading lua config file %s: %v\n", luaFile, err)
+	}
+	return true
+}
+
+// bindLuaConfig loads a Lua config into Viper like BindToViper and records it, together with the
+// schema it declares with culebra.schema, its data in declaration order and the origins of its
+// keys, for the config command and --set completion
+func bindLuaConfig(cfg Config) error {
+	cfg.trackOrigins = true
+	L, table, err := evaluate(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to load lua config: %w", err)
+	}
+	defer L.Close()
+
+	data, err := cfg.convert(L, table)
+	if err != nil {
+		return fmt.Errorf("failed to load lua config: %w", err)
+	}
+	ordered, err := internal.ConvertOrderedTable(L, table, cfg.convertOptions())
+	if err != nil {
+		return fmt.Errorf("failed to load lua config: failed to convert lua config: %w", err)
+	}
+	for key, value := range data {
+		viper.Set(key, value)
+	}
+
+	if declared := declaredSchema(L); declared != nil {
+		cfg.declared = []*Schema{declared}
+	}
+	cfg.bound = ordered
+	cfg.boundOrigins = []map[string]Origin{evaluationOf(L).keyOrigins(L, table)}
+	setLuaConfigUsed(cfg)
+	return nil
 }
@@ -0,0 +1,335 @@
+package culebra
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/Fuabioo/culebra/internal"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// CommandsKey is the config key holding commands defined in Lua
+const CommandsKey = "commands"
+
+// RegisterLuaCommands evaluates a Lua config and adds every entry of its commands table to parent.
+// A table entry defines a command with help text, flags and a run(args, flags) function,
+// while a string entry defines an alias that expands into another command line:
+//
+//	commands = {
+//	    deploy = {
+//	        short = "Deploy the application",
+//	        flags = { env = { type = "string", default = "dev", usage = "target environment" } },
+//	        run = function(args, flags) print("deploying to " .. flags.env) end,
+//	    },
+//	    st = "status --short",
+//	}
+//
+// The config is evaluated once, with the cli global describing parent. The Lua state stays open
+// so run functions share it; when a command runs, cli describes that command instead.
+func RegisterLuaCommands(parent *cobra.Command, cfg Config) error {
+	globals := make(map[string]any, len(cfg.Globals)+1)
+	for key, value := range cfg.Globals {
+		globals[key] = value
+	}
+	if _, ok := globals[CLIGlobal]; !ok {
+		globals[CLIGlobal] = cliGlobals(parent)
+	}
+	cfg.Globals = globals
+
+	L, table, err := evaluate(cfg)
+	if err != nil {
+		return err
+	}
+
+	commands, err := luaCommands(L, parent, table)
+	if err != nil || len(commands) == 0 {
+		L.Close()
+		return err
+	}
+
+	parent.AddCommand(commands...)
+	return nil
+}
+
+// luaCommands builds the commands declared in the commands table of an evaluated config
+func luaCommands(L *lua.LState, parent *cobra.Command, table *lua.LTable) ([]*cobra.Command, error) {
+	definitions := table.RawGetString(CommandsKey)
+	if definitions == lua.LNil {
+		return nil, nil
+	}
+	commands, ok := definitions.(*lua.LTable)
+	if !ok {
+		return nil, fmt.Errorf("%s must be a table, got %s", CommandsKey, definitions.Type())
+	}
+
+	var cmds []*cobra.Command
+	var registerErr error
+	commands.ForEach(func(key, value lua.LValue) {
+		if registerErr != nil {
+			return
+		}
+
+		name := key.String()
+		switch definition := value.(type) {
+		case lua.LString:
+			cmds = append(cmds, luaAliasCommand(parent, name, string(definition)))
+		case *lua.LTable:
+			cmd, err := luaCommand(L, parent, name, definition)
+			if err != nil {
+				registerErr = err
+				return
+			}
+			cmds = append(cmds, cmd)
+		default:
+			registerErr = fmt.Errorf("command %q must be a table or an alias string, got %s", name, value.Type())
+		}
+	})
+
+	return cmds, registerErr
+}
+
+// luaCommand builds a cobra command from its Lua definition, to be added to parent
+func luaCommand(L *lua.LState, parent *cobra.Command, name string, definition *lua.LTable) (*cobra.Command, error) {
+	run, ok := definition.RawGetString("run").(*lua.LFunction)
+	if !ok {
+		return nil, fmt.Errorf("command %q requires a run function", name)
+	}
+
+	use := name
+	if value, ok := definition.RawGetString("use").(lua.LString); ok {
+		use = string(value)
+	}
+
+	cmd := &cobra.Command{
+		Use:     use,
+		Short:   luaStringField(definition, "short"),
+		Long:    luaStringField(definition, "long"),
+		Example: luaStringField(definition, "example"),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runLuaCommand(L, cmd, name, run)
+		},
+	}
+
+	if aliases, ok := definition.RawGetString("aliases").(*lua.LTable); ok {
+		aliases.ForEach(func(_, alias lua.LValue) {
+			cmd.Aliases = append(cmd.Aliases, alias.String())
+		})
+	}
+
+	if flags, ok := definition.RawGetString("flags").(*lua.LTable); ok {
+		var flagErr error
+		flags.ForEach(func(key, spec lua.LValue) {
+			if flagErr == nil {
+				flagErr = addLuaFlag(parent, cmd.Flags(), key.String(), spec)
+			}
+		})
+		if flagErr != nil {
+			return nil, fmt.Errorf("command %q: %w", name, flagErr)
+		}
+	}
+
+	return cmd, nil
+}
+
+// addLuaFlag declares a flag from its Lua spec, either a table with type, default, usage and
+// shorthand fields or a bare default value whose Lua type determines the flag type. The flag is
+// checked against the flags of the command and the persistent flags of parent and its ancestors,
+// since pflag panics on invalid or duplicate shorthands.
+func addLuaFlag(parent *cobra.Command, flags *pflag.FlagSet, name string, spec lua.LValue) error {
+	var flagType, usage, shorthand string
+	defaultValue := spec
+
+	if table, ok := spec.(*lua.LTable); ok {
+		flagType = luaStringField(table, "type")
+		usage = luaStringField(table, "usage")
+		shorthand = luaStringField(table, "shorthand")
+		defaultValue = table.RawGetString("default")
+	}
+
+	if shorthand != "" {
+		if err := checkShorthand(parent, flags, shorthand); err != nil {
+			return fmt.Errorf("flag %q: %w", name, err)
+		}
+	}
+
+	if flagType == "" {
+		switch defaultValue.(type) {
+		case lua.LBool:
+			flagType = "bool"
+		case lua.LNumber:
+			flagType = "number"
+		case *lua.LTable:
+			flagType = "strings"
+		default:
+			flagType = "string"
+		}
+	}
+
+	switch flagType {
+	case "string":
+		value := ""
+		if defaultValue != lua.LNil {
+			value = defaultValue.String()
+		}
+		flags.StringP(name, shorthand, value, usage)
+	case "bool":
+		flags.BoolP(name, shorthand, lua.LVAsBool(defaultValue), usage)
+	case "int":
+		value, _ := defaultValue.(lua.LNumber)
+		flags.IntP(name, shorthand, int(value), usage)
+	case "number":
+		value, _ := defaultValue.(lua.LNumber)
+		flags.Float64P(name, shorthand, float64(value), usage)
+	case "strings":
+		var values []string
+		if table, ok := defaultValue.(*lua.LTable); ok {
+			for i := 1; i <= table.Len(); i++ {
+				values = append(values, table.RawGetInt(i).String())
+			}
+		}
+		flags.StringSliceP(name, shorthand, values, usage)
+	default:
+		return fmt.Errorf("flag %q has unsupported type %q", name, flagType)
+	}
+
+	return nil
+}
+
+// checkShorthand reports a shorthand that is not a single ASCII character or is already taken
+func checkShorthand(parent *cobra.Command, flags *pflag.FlagSet, shorthand string) error {
+	if len(shorthand) != 1 || shorthand[0] > unicode.MaxASCII {
+		return fmt.Errorf("shorthand %q must be a single ASCII character", shorthand)
+	}
+	if existing := flags.ShorthandLookup(shorthand); existing != nil {
+		return fmt.Errorf("shorthand %q is already used by --%s", shorthand, existing.Name)
+	}
+	for c := parent; c != nil; c = c.Parent() {
+		if existing := c.PersistentFlags().ShorthandLookup(shorthand); existing != nil {
+			return fmt.Errorf("shorthand %q is already used by --%s of %s", shorthand, existing.Name, c.Name())
+		}
+	}
+	return nil
+}
+
+// runLuaCommand points the cli global at cmd and calls the command's run function with the
+// positional args and the parsed flags
+func runLuaCommand(L *lua.LState, cmd *cobra.Command, name string, run *lua.LFunction) error {
+	invoked, err := internal.GoToLuaNamed(L, CLIGlobal, cliGlobals(cmd))
+	if err != nil {
+		return fmt.Errorf("command %q: %w", name, err)
+	}
+
+	cli := L.GetGlobal(CLIGlobal)
+	if !internal.Refreeze(cli, invoked) {
+		cli = invoked
+	}
+
+	if err := L.CallByParam(lua.P{Fn: run, NRet: 0, Protect: true}, L.GetField(cli, "args"), L.GetField(cli, "flags")); err != nil {
+		return fmt.Errorf("command %q failed: %w", name, err)
+	}
+
+	return nil
+}
+
+// luaAliasCommand builds a command that finds the command named by the alias expansion and runs
+// it, with its hooks, on the expanded arguments followed by the arguments given to the alias.
+// A --help among them prints the help of that command.
+func luaAliasCommand(parent *cobra.Command, name, expansion string) *cobra.Command {
+	expanding := false
+
+	return &cobra.Command{
+		Use:                name,
+		Short:              fmt.Sprintf("Alias for %q", expansion),
+		DisableFlagParsing: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if expanding {
+				return fmt.Errorf("alias %q expands to itself", name)
+			}
+			expanding = true
+			defer func() { expanding = false }()
+
+			target, targetArgs, err := parent.Root().Find(append(strings.Fields(expansion), args...))
+			if err != nil {
+				return fmt.Errorf("alias %q: %w", name, err)
+			}
+
+			if !target.DisableFlagParsing {
+				target.InitDefaultHelpFlag()
+				if err := target.ParseFlags(targetArgs); err != nil {
+					return fmt.Errorf("alias %q: %w", name, err)
+				}
+				if help, err := target.Flags().GetBool("help"); err == nil && help {
+					return target.Help()
+				}
+				targetArgs = target.Flags().Args()
+			}
+			if !target.Runnable() {
+				return fmt.Errorf("alias %q expands to %q, which is not runnable", name, target.CommandPath())
+			}
+			if err := target.ValidateArgs(targetArgs); err != nil {
+				return fmt.Errorf("alias %q: %w", name, err)
+			}
+
+			return runAliasTarget(cmd, target, targetArgs)
+		},
+	}
+}
+
+// runAliasTarget runs the hooks and the run function of an alias target in Cobra's order. Like
+// Cobra, only the nearest persistent hooks run, and those the alias shares with its target
+// already ran for the alias itself.
+func runAliasTarget(alias, target *cobra.Command, args []string) error {
+	preRun := func(c *cobra.Command) bool { return c.PersistentPreRunE != nil || c.PersistentPreRun != nil }
+	postRun := func(c *cobra.Command) bool { return c.PersistentPostRunE != nil || c.PersistentPostRun != nil }
+
+	if owner := nearestHook(target, preRun); owner != nil && owner != nearestHook(alias, preRun) {
+		if err := runHook(owner.PersistentPreRunE, owner.PersistentPreRun, target, args); err != nil {
+			return err
+		}
+	}
+	if err := runHook(target.PreRunE, target.PreRun, target, args); err != nil {
+		return err
+	}
+	if err := runHook(target.RunE, target.Run, target, args); err != nil {
+		return err
+	}
+	if err := runHook(target.PostRunE, target.PostRun, target, args); err != nil {
+		return err
+	}
+	if owner := nearestHook(target, postRun); owner != nil && owner != nearestHook(alias, postRun) {
+		return runHook(owner.PersistentPostRunE, owner.PersistentPostRun, target, args)
+	}
+	return nil
+}
+
+// nearestHook returns cmd or its closest parent for which has reports a hook, or nil
+func nearestHook(cmd *cobra.Command, has func(*cobra.Command) bool) *cobra.Command {
+	for c := cmd; c != nil; c = c.Parent() {
+		if has(c) {
+			return c
+		}
+	}
+	return nil
+}
+
+// runHook calls the error-returning form of a Cobra hook when set, and its plain form otherwise
+func runHook(withErr func(*cobra.Command, []string) error, plain func(*cobra.Command, []string), cmd *cobra.Command, args []string) error {
+	switch {
+	case withErr != nil:
+		return withErr(cmd, args)
+	case plain != nil:
+		plain(cmd, args)
+	}
+	return nil
+}
+
+// luaStringField returns a string field of a Lua table, or an empty string when it is not set
+func luaStringField(table *lua.LTable, field string) string {
+	if value, ok := table.RawGetString(field).(lua.LString); ok {
+		return string(value)
+	}
+	return ""
+}
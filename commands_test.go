@@ -0,0 +1,258 @@
+package culebra
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+const commandsConfig = `
+local function record(line)
+    local file = assert(io.open(output, "a"))
+    file:write(line .. "\n")
+    file:close()
+end
+
+return {
+    commands = {
+        greet = {
+            short = "Greet someone",
+            aliases = { "hi" },
+            flags = {
+                name = { type = "string", default = "world", usage = "who to greet", shorthand = "n" },
+                times = { type = "int", default = 1 },
+                loud = false,
+            },
+            run = function(args, flags)
+                local greeting = "hello " .. flags.name
+                if flags.loud then
+                    greeting = string.upper(greeting)
+                end
+                record(greeting .. " x" .. flags.times .. " " .. (args[1] or "-"))
+            end,
+        },
+        fail = {
+            run = function() error("boom") end,
+        },
+        hey = "greet --loud",
+    },
+}
+`
+
+func newLuaCommandsRoot(t *testing.T) (*cobra.Command, string) {
+	t.Helper()
+
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "test.lua")
+	output := filepath.Join(tmpDir, "output.txt")
+
+	if err := os.WriteFile(configFile, []byte(commandsConfig), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	root := &cobra.Command{Use: "myapp", SilenceUsage: true, SilenceErrors: true}
+	cfg := Config{FilePath: configFile, Globals: map[string]any{"output": output}}
+	if err := RegisterLuaCommands(root, cfg); err != nil {
+		t.Fatalf("RegisterLuaCommands failed: %v", err)
+	}
+
+	return root, output
+}
+
+func TestRegisterLuaCommands(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		expected string
+	}{
+		{"defaults", []string{"greet"}, "hello world x1 -"},
+		{"flags and args", []string{"greet", "-n", "lua", "--times", "3", "extra"}, "hello lua x3 extra"},
+		{"cobra alias", []string{"hi", "--loud"}, "HELLO WORLD x1 -"},
+		{"lua alias", []string{"hey", "--name", "bob"}, "HELLO BOB x1 -"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root, output := newLuaCommandsRoot(t)
+			root.SetArgs(tt.args)
+			if err := root.Execute(); err != nil {
+				t.Fatalf("Execute failed: %v", err)
+			}
+
+			content, err := os.ReadFile(output)
+			if err != nil {
+				t.Fatalf("Failed to read output: %v", err)
+			}
+			if got := strings.TrimSpace(string(content)); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestRegisterLuaCommandsHelp(t *testing.T) {
+	root, _ := newLuaCommandsRoot(t)
+
+	greet, _, err := root.Find([]string{"greet"})
+	if err != nil {
+		t.Fatalf("Find failed: %v", err)
+	}
+	if greet.Short != "Greet someone" {
+		t.Errorf("Expected short help, got %q", greet.Short)
+	}
+	if flag := greet.Flags().Lookup("name"); flag == nil || flag.Usage != "who to greet" || flag.Shorthand != "n" {
+		t.Errorf("Expected name flag with usage and shorthand, got %+v", flag)
+	}
+}
+
+func TestRegisterLuaCommandsRunError(t *testing.T) {
+	root, _ := newLuaCommandsRoot(t)
+	root.SetArgs([]string{"fail"})
+
+	err := root.Execute()
+	if err == nil {
+		t.Fatal("Expected error from failing command")
+	}
+	if !strings.Contains(err.Error(), "boom") {
+		t.Errorf("Expected Lua error message, got %v", err)
+	}
+}
+
+func TestRegisterLuaCommandsInvalid(t *testing.T) {
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "test.lua")
+
+	if err := os.WriteFile(configFile, []byte(`commands = { broken = { short = "no run" } }`), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	if err := RegisterLuaCommands(&cobra.Command{Use: "myapp"}, Config{FilePath: configFile}); err == nil {
+		t.Error("Expected error for command without run function")
+	}
+}
+
+func TestRegisterLuaCommandsInvalidShorthand(t *testing.T) {
+	tests := []struct {
+		name     string
+		commands string
+		expected string
+	}{
+		{"long shorthand", `{ deploy = { flags = { env = { shorthand = "env" } }, run = function() end } }`, "single ASCII character"},
+		{"duplicate shorthand", `{ deploy = { flags = { a = { shorthand = "x" }, b = { shorthand = "x" } }, run = function() end } }`, "already used"},
+		{"persistent shorthand", `{ deploy = { flags = { verbose = { shorthand = "v", type = "bool" } }, run = function() end } }`, "already used by --verbose of myapp"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configFile := filepath.Join(t.TempDir(), "test.lua")
+			if err := os.WriteFile(configFile, []byte("commands = "+tt.commands), 0644); err != nil {
+				t.Fatalf("Failed to write test config: %v", err)
+			}
+
+			root := &cobra.Command{Use: "myapp"}
+			root.PersistentFlags().BoolP("verbose", "v", false, "verbose output")
+			err := RegisterLuaCommands(root, Config{FilePath: configFile})
+			if err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("Expected error containing %q, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestRegisterLuaCommandsEvaluatesOnce(t *testing.T) {
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "test.lua")
+	output := filepath.Join(tmpDir, "output.txt")
+
+	configContent := `
+local function record(line)
+    local file = assert(io.open(output, "a"))
+    file:write(line .. "\n")
+    file:close()
+end
+
+record("evaluated for " .. cli.command)
+
+commands = {
+    show = {
+        flags = { env = "dev" },
+        run = function(args, flags)
+            record(cli.command .. " " .. flags.env .. " " .. cli.flags.env .. " " .. #args)
+        end,
+    },
+    prod = "show --env production",
+}
+`
+
+	if err := os.WriteFile(configFile, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	root := &cobra.Command{Use: "myapp", SilenceUsage: true, SilenceErrors: true}
+	cfg := Config{FilePath: configFile, Globals: map[string]any{"output": output}}
+	if err := RegisterLuaCommands(root, cfg); err != nil {
+		t.Fatalf("RegisterLuaCommands failed: %v", err)
+	}
+
+	root.SetArgs([]string{"show", "a", "b"})
+	if err := root.Execute(); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	root.SetArgs([]string{"prod"})
+	if err := root.Execute(); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+
+	content, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("Failed to read output: %v", err)
+	}
+	expected := "evaluated for myapp\nmyapp show dev dev 2\nmyapp show production production 0"
+	if got := strings.TrimSpace(string(content)); got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
+
+func TestLuaAliasRunsHooksAndHelp(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "test.lua")
+	if err := os.WriteFile(configFile, []byte(`commands = { dep = "ops deploy now" }`), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	var calls []string
+	record := func(name string) func(*cobra.Command, []string) {
+		return func(cmd *cobra.Command, args []string) { calls = append(calls, name+" "+strings.Join(args, " ")) }
+	}
+	root := &cobra.Command{Use: "myapp", SilenceUsage: true, SilenceErrors: true, PersistentPreRun: record("root-persistent")}
+	ops := &cobra.Command{Use: "ops", PersistentPreRun: record("ops-persistent"), PersistentPostRun: record("ops-persistent-post")}
+	deploy := &cobra.Command{Use: "deploy", PreRun: record("pre"), Run: record("run"), PostRun: record("post")}
+	ops.AddCommand(deploy)
+	root.AddCommand(ops)
+	if err := RegisterLuaCommands(root, Config{FilePath: configFile}); err != nil {
+		t.Fatalf("RegisterLuaCommands failed: %v", err)
+	}
+
+	root.SetArgs([]string{"dep", "fast"})
+	if err := root.Execute(); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	expected := []string{"root-persistent fast", "ops-persistent now fast", "pre now fast", "run now fast", "post now fast", "ops-persistent-post now fast"}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Errorf("Expected hooks %v, got %v", expected, calls)
+	}
+
+	var out strings.Builder
+	root.SetOut(&out)
+	root.SetArgs([]string{"dep", "--help"})
+	calls = nil
+	if err := root.Execute(); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "myapp ops deploy") || !reflect.DeepEqual(calls, []string{"root-persistent --help"}) {
+		t.Errorf("Expected help of the target without running it, got %q and %v", out.String(), calls)
+	}
+}
@@ -0,0 +1,219 @@
+package culebra
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// completionAnnotation prefixes the command annotations holding values registered for a key
+const completionAnnotation = "culebra.completion."
+
+// RegisterCompletionValues declares the values offered when completing --set key= on cmd and its
+// subcommands, typically for settings without a schema. Enum values declared by Config.Schema or
+// culebra.schema are offered without registration.
+func RegisterCompletionValues(cmd *cobra.Command, key string, values ...string) {
+	if cmd.Annotations == nil {
+		cmd.Annotations = make(map[string]string)
+	}
+	cmd.Annotations[completionAnnotation+strings.ToLower(key)] = strings.Join(values, "\n")
+}
+
+// CompleteConfigKeys is a cobra ValidArgsFunction completing dotted config keys from the evaluated config
+func CompleteConfigKeys(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return completeKeys(viper.GetViper(), toComplete, ""), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeSetFlag completes --set assignments: keys followed by "=" and then the declared values for the key
+func completeSetFlag(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if key, _, found := strings.Cut(toComplete, "="); found {
+		return completeValues(cmd, key, toComplete), cobra.ShellCompDirectiveNoFileComp
+	}
+	return completeKeys(viper.GetViper(), toComplete, "="), cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveNoSpace
+}
+
+// completeKeys returns the config keys and their parent paths starting with prefix, each followed by suffix
+func completeKeys(v *viper.Viper, prefix, suffix string) []string {
+	seen := make(map[string]bool)
+	var candidates []string
+
+	add := func(key string) {
+		if !seen[key] && strings.HasPrefix(key, strings.ToLower(prefix)) {
+			seen[key] = true
+			candidates = append(candidates, key+suffix)
+		}
+	}
+
+	for _, key := range v.AllKeys() {
+		parts := strings.Split(key, ".")
+		for i := 1; i < len(parts); i++ {
+			add(strings.Join(parts[:i], "."))
+		}
+		add(key)
+	}
+
+	sort.Strings(candidates)
+	return candidates
+}
+
+// completeValues returns the "key=value" candidates declared for key that start with toComplete,
+// from the values registered on cmd or its parents and the enums of the active config's schemas
+func completeValues(cmd *cobra.Command, key, toComplete string) []string {
+	var values []string
+	for c := cmd; c != nil; c = c.Parent() {
+		if registered, ok := c.Annotations[completionAnnotation+strings.ToLower(key)]; ok {
+			values = strings.Split(registered, "\n")
+			break
+		}
+	}
+	values = append(values, schemaValues(key)...)
+
+	seen := make(map[string]bool)
+	var candidates []string
+	for _, value := range values {
+		candidate := key + "=" + value
+		if !seen[candidate] && strings.HasPrefix(candidate, toComplete) {
+			seen[candidate] = true
+			candidates = append(candidates, candidate)
+		}
+	}
+	return candidates
+}
+
+// schemaValues returns the enum members declared for key by the schemas of the Lua config bound
+// by the Cobra integration. The config is not evaluated again: culebra.schema declarations are
+// the ones recorded when it was bound.
+func schemaValues(key string) []string {
+	cfg, ok := luaConfigUsedByCobra()
+	if !ok {
+		return nil
+	}
+
+	schemas, err := cfg.schemas(cfg.declared...)
+	if err != nil {
+		return nil
+	}
+
+	var values []string
+	for _, schema := range schemas {
+		if field := schema.lookup(key); field != nil {
+			for _, member := range field.Enum {
+				values = append(values, fmt.Sprint(member))
+			}
+		}
+	}
+	return values
+}
+
+// NewConfigCommand returns a "config" command for inspecting the loaded configuration.
+// "get <key>" prints one value and completes keys in the shell. "show" prints the whole
+// configuration in declaration order. "explain <key>" prints the file and line that set a key.
+func NewConfigCommand() *cobra.Command {
+	configCmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect the loaded configuration",
+	}
+
+	configCmd.AddCommand(&cobra.Command{
+		Use:               "get <key>",
+		Short:             "Print the value of a config key",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: CompleteConfigKeys,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !viper.IsSet(args[0]) {
+				return fmt.Errorf("config key not set: %s", args[0])
+			}
+			return printConfigValue(cmd, viper.Get(args[0]))
+		},
+	})
+
+	configCmd.AddCommand(&cobra.Command{
+		Use:   "show",
+		Short: "Print the whole configuration",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// The data recorded when the config was bound, in declaration order for a single file
+			if cfg, ok := luaConfigUsedByCobra(); ok && cfg.bound != nil {
+				return printConfigValue(cmd, cfg.bound)
+			}
+			return printConfigValue(cmd, viper.AllSettings())
+		},
+	})
+
+	configCmd.AddCommand(&cobra.Command{
+		Use:               "explain <key>",
+		Short:             "Print the value of a config key and the Lua file and line that set it",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: CompleteConfigKeys,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return explainConfigKey(cmd, args[0])
+		},
+	})
+
+	return configCmd
+}
+
+// explainConfigKey prints the value of key followed by the origin recorded when the config was bound
+func explainConfigKey(cmd *cobra.Command, key string) error {
+	if !viper.IsSet(key) {
+		return fmt.Errorf("config key not set: %s", key)
+	}
+	if err := printConfigValue(cmd, viper.Get(key)); err != nil {
+		return err
+	}
+
+	cfg, ok := luaConfigUsedByCobra()
+	if !ok {
+		cmd.Println("not set by a Lua config")
+		return nil
+	}
+	origin, ok := configOrigin(cfg, key)
+	switch {
+	case !ok:
+		cmd.Printf("not assigned in %s\n", cfg.FilePath)
+	case !strings.EqualFold(origin.Key, key):
+		cmd.Printf("set as part of %s at %s\n", origin.Key, origin)
+	default:
+		cmd.Printf("set at %s\n", origin)
+	}
+	return nil
+}
+
+// configOrigin returns the origin of key recorded when the config was bound. For a cascade the
+// innermost file assigning key wins, falling back to the innermost file assigning one of its parents.
+func configOrigin(cfg Config, key string) (Origin, bool) {
+	var parent Origin
+	var found bool
+	for i := len(cfg.boundOrigins) - 1; i >= 0; i-- {
+		origin, ok := lookupOrigin(cfg.boundOrigins[i], key)
+		switch {
+		case ok && strings.EqualFold(origin.Key, key):
+			return origin, true
+		case ok && !found:
+			parent, found = origin, true
+		}
+	}
+	return parent, found
+}
+
+// printConfigValue prints scalars as-is and nested values as indented JSON
+func printConfigValue(cmd *cobra.Command, value any) error {
+	switch value.(type) {
+	case map[string]any, []any, *OrderedMap:
+		encoded, err := json.MarshalIndent(value, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode config value: %w", err)
+		}
+		cmd.Println(string(encoded))
+	default:
+		cmd.Println(value)
+	}
+	return nil
+}
@@ -0,0 +1,243 @@
+package culebra
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func TestCompleteKeys(t *testing.T) {
+	v := viper.New()
+	v.Set("database.host", "localhost")
+	v.Set("database.port", 5432)
+	v.Set("debug", true)
+
+	tests := []struct {
+		name     string
+		prefix   string
+		suffix   string
+		expected []string
+	}{
+		{"all keys", "", "", []string{"database", "database.host", "database.port", "debug"}},
+		{"nested prefix", "database.", "", []string{"database.host", "database.port"}},
+		{"with suffix", "deb", "=", []string{"debug="}},
+		{"no match", "missing", "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := completeKeys(v, tt.prefix, tt.suffix)
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestCompleteValues(t *testing.T) {
+	root := &cobra.Command{Use: "myapp"}
+	child := &cobra.Command{Use: "serve"}
+	root.AddCommand(child)
+	RegisterCompletionValues(root, "log.level", "debug", "info", "warn")
+
+	result := completeValues(child, "log.level", "log.level=d")
+	expected := []string{"log.level=debug"}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("expected %v, got %v", expected, result)
+	}
+
+	if result := completeValues(&cobra.Command{Use: "other"}, "log.level", "log.level="); result != nil {
+		t.Errorf("expected values to be scoped to the command, got %v", result)
+	}
+	if result := completeValues(child, "unknown", "unknown="); result != nil {
+		t.Errorf("expected no candidates, got %v", result)
+	}
+}
+
+func TestCompleteSchemaValues(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "test.lua")
+	configContent := `
+culebra.schema{ server = { mode = culebra.string{ enum = { "fast", "safe" } } } }
+return { level = "info", server = { mode = "fast" }, started = tick() }
+`
+	if err := os.WriteFile(configFile, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	type appConfig struct {
+		Level string `culebra:"level,enum=debug|info"`
+	}
+	calls := 0
+	tick := func() int { calls++; return calls }
+	if err := bindLuaConfig(Config{FilePath: configFile, Schema: appConfig{}, Globals: map[string]any{"tick": tick}}); err != nil {
+		t.Fatalf("bindLuaConfig failed: %v", err)
+	}
+	defer viper.Reset()
+	defer func() {
+		luaConfigUsedMu.Lock()
+		luaConfigUsed = nil
+		luaConfigUsedMu.Unlock()
+	}()
+
+	cmd := &cobra.Command{Use: "myapp"}
+	if result, expected := completeValues(cmd, "level", "level="), []string{"level=debug", "level=info"}; !reflect.DeepEqual(result, expected) {
+		t.Errorf("expected %v, got %v", expected, result)
+	}
+	if result, expected := completeValues(cmd, "Server.Mode", "Server.Mode=s"), []string{"Server.Mode=safe"}; !reflect.DeepEqual(result, expected) {
+		t.Errorf("expected %v, got %v", expected, result)
+	}
+	if calls != 1 {
+		t.Errorf("expected completion not to evaluate the config again, got %d evaluations", calls)
+	}
+}
+
+func TestConfigGetCommand(t *testing.T) {
+	viper.Set("completion_test.name", "culebra")
+	defer viper.Reset()
+
+	cmd := NewConfigCommand()
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"get", "completion_test.name"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if strings.TrimSpace(out.String()) != "culebra" {
+		t.Errorf("expected culebra, got %q", out.String())
+	}
+
+	cmd.SetArgs([]string{"get", "completion_test.missing"})
+	cmd.SilenceUsage = true
+	cmd.SilenceErrors = true
+	if err := cmd.Execute(); err == nil {
+		t.Error("expected error for missing key")
+	}
+}
+
+func TestConfigShowCommand(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "test.lua")
+	if err := os.WriteFile(configFile, []byte(`culebra.warn("deprecated") return { zeta = tick(), alpha = { second = true, first = "x" } }`), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	cmd := NewConfigCommand()
+	var out, errOut bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetErr(&errOut)
+
+	calls := 0
+	tick := func() int { calls++; return calls }
+	if err := bindLuaConfig(Config{FilePath: configFile, Globals: map[string]any{"tick": tick}, OnWarning: printWarning(cmd)}); err != nil {
+		t.Fatalf("bindLuaConfig failed: %v", err)
+	}
+	defer viper.Reset()
+	defer func() {
+		luaConfigUsedMu.Lock()
+		luaConfigUsed = nil
+		luaConfigUsedMu.Unlock()
+	}()
+	errOut.Reset()
+
+	cmd.SetArgs([]string{"show"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if errOut.Len() > 0 {
+		t.Errorf("Expected warnings printed when binding not to be repeated, got %q", errOut.String())
+	}
+	if calls != 1 {
+		t.Errorf("Expected show not to evaluate the config again, got %d evaluations", calls)
+	}
+
+	compact := strings.Join(strings.Fields(out.String()), "")
+	if expected := `{"zeta":1,"alpha":{"second":true,"first":"x"}}`; compact != expected {
+		t.Errorf("Expected %s, got %s", expected, compact)
+	}
+}
+
+func TestConfigExplainCommand(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "test.lua")
+	configContent := "database = {\n    host = \"localhost\",\n}\ndatabase.port = port()\n"
+	if err := os.WriteFile(configFile, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	calls := 0
+	port := func() int { calls++; return 5431 + calls }
+	if err := bindLuaConfig(Config{FilePath: configFile, Globals: map[string]any{"port": port}}); err != nil {
+		t.Fatalf("bindLuaConfig failed: %v", err)
+	}
+	defer viper.Reset()
+	defer func() {
+		luaConfigUsedMu.Lock()
+		luaConfigUsed = nil
+		luaConfigUsedMu.Unlock()
+	}()
+
+	tests := []struct {
+		key      string
+		expected string
+	}{
+		{"database.host", "localhost\nset at " + configFile + ":2"},
+		{"database.port", "5432\nset at " + configFile + ":4"},
+	}
+
+	for _, tt := range tests {
+		cmd := NewConfigCommand()
+		var out bytes.Buffer
+		cmd.SetOut(&out)
+		cmd.SetArgs([]string{"explain", tt.key})
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("Execute failed: %v", err)
+		}
+		if got := strings.TrimSpace(out.String()); got != tt.expected {
+			t.Errorf("Expected %q, got %q", tt.expected, got)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("Expected explain not to evaluate the config again, got %d evaluations", calls)
+	}
+}
+
+func TestConfigOriginCascade(t *testing.T) {
+	top := t.TempDir()
+	module := filepath.Join(top, "module")
+	topFile := writeCascadeFile(t, top, "server = {\n    host = \"top\",\n}\n")
+	moduleFile := writeCascadeFile(t, module, "server = {\n    port = 8080,\n}\n")
+	t.Chdir(module)
+
+	defer viper.Reset()
+	defer func() {
+		luaConfigUsedMu.Lock()
+		luaConfigUsed = nil
+		luaConfigUsedMu.Unlock()
+	}()
+	loadCascadeConfig(&cobra.Command{Use: "culebra-cascade-test"}, CobraOptions{}, nil)
+	cfg, ok := luaConfigUsedByCobra()
+	if !ok {
+		t.Fatal("expected cascade load to record the config")
+	}
+
+	tests := []struct {
+		key      string
+		expected string
+	}{
+		{"server.host", topFile + ":2"},
+		{"server.port", moduleFile + ":2"},
+	}
+	for _, tt := range tests {
+		origin, ok := configOrigin(cfg, tt.key)
+		if !ok {
+			t.Fatalf("configOrigin(%s) found no origin", tt.key)
+		}
+		if origin.String() != tt.expected {
+			t.Errorf("Expected %s for %s, got %s", tt.expected, tt.key, origin)
+		}
+	}
+}
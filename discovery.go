@@ -0,0 +1,81 @@
+package culebra
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrConfigNotFound is matched by errors returned when no candidate config file exists
+var ErrConfigNotFound = errors.New("lua config not found")
+
+// NotFoundError reports every candidate path checked while looking for a config file
+type NotFoundError struct {
+	Candidates []string
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("%v, checked: %s", ErrConfigNotFound, strings.Join(e.Candidates, ", "))
+}
+
+func (e *NotFoundError) Is(target error) bool {
+	return target == ErrConfigNotFound
+}
+
+// ConfigCandidates returns the locations of an application's Lua config in precedence order:
+// $XDG_CONFIG_HOME/<app> (defaulting to ~/.config/<app>), each entry of $XDG_CONFIG_DIRS
+// (defaulting to /etc/xdg), ~/.<app> and finally /etc/<app>, each holding <name>.lua
+func ConfigCandidates(app, name string) []string {
+	fileName := name + ".lua"
+	home, _ := os.UserHomeDir()
+
+	var dirs []string
+
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if !filepath.IsAbs(configHome) && home != "" {
+		configHome = filepath.Join(home, ".config")
+	}
+	if filepath.IsAbs(configHome) {
+		dirs = append(dirs, filepath.Join(configHome, app))
+	}
+
+	configDirs := os.Getenv("XDG_CONFIG_DIRS")
+	if configDirs == "" {
+		configDirs = "/etc/xdg"
+	}
+	for _, dir := range filepath.SplitList(configDirs) {
+		// Relative entries are invalid per the XDG specification
+		if filepath.IsAbs(dir) {
+			dirs = append(dirs, filepath.Join(dir, app))
+		}
+	}
+
+	if home != "" {
+		dirs = append(dirs, filepath.Join(home, "."+app))
+	}
+	dirs = append(dirs, filepath.Join("/etc", app))
+
+	candidates := make([]string, len(dirs))
+	for i, dir := range dirs {
+		candidates[i] = filepath.Join(dir, fileName)
+	}
+	return candidates
+}
+
+// FindConfig returns the first candidate that exists as a regular file,
+// or a *NotFoundError listing every candidate when none does
+func FindConfig(candidates []string) (string, error) {
+	for _, candidate := range candidates {
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			return candidate, nil
+		}
+	}
+	return "", &NotFoundError{Candidates: candidates}
+}
+
+// DiscoverConfig finds an application's Lua config in the locations returned by ConfigCandidates
+func DiscoverConfig(app, name string) (string, error) {
+	return FindConfig(ConfigCandidates(app, name))
+}
@@ -0,0 +1,93 @@
+package culebra
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestConfigCandidates(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "/xdg/home")
+	t.Setenv("XDG_CONFIG_DIRS", "/xdg/one:relative:/xdg/two")
+
+	expected := []string{
+		"/xdg/home/myapp/config.lua",
+		"/xdg/one/myapp/config.lua",
+		"/xdg/two/myapp/config.lua",
+		filepath.Join(home, ".myapp", "config.lua"),
+		"/etc/myapp/config.lua",
+	}
+
+	if result := ConfigCandidates("myapp", "config"); !reflect.DeepEqual(result, expected) {
+		t.Errorf("expected %v, got %v", expected, result)
+	}
+}
+
+func TestConfigCandidatesDefaults(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("XDG_CONFIG_DIRS", "")
+
+	expected := []string{
+		filepath.Join(home, ".config", "myapp", "myapp.lua"),
+		"/etc/xdg/myapp/myapp.lua",
+		filepath.Join(home, ".myapp", "myapp.lua"),
+		"/etc/myapp/myapp.lua",
+	}
+
+	if result := ConfigCandidates("myapp", "myapp"); !reflect.DeepEqual(result, expected) {
+		t.Errorf("expected %v, got %v", expected, result)
+	}
+}
+
+func TestDiscoverConfigPrecedence(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, "xdg"))
+	t.Setenv("XDG_CONFIG_DIRS", filepath.Join(home, "system"))
+
+	dotDir := filepath.Join(home, ".culebra-test")
+	systemDir := filepath.Join(home, "system", "culebra-test")
+	for _, dir := range []string{dotDir, systemDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "config.lua"), []byte("debug = true"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	path, err := DiscoverConfig("culebra-test", "config")
+	if err != nil {
+		t.Fatalf("DiscoverConfig failed: %v", err)
+	}
+	if expected := filepath.Join(systemDir, "config.lua"); path != expected {
+		t.Errorf("expected %s, got %s", expected, path)
+	}
+}
+
+func TestDiscoverConfigNotFound(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("XDG_CONFIG_DIRS", "")
+
+	_, err := DiscoverConfig("culebra-test-missing", "config")
+	if !errors.Is(err, ErrConfigNotFound) {
+		t.Fatalf("expected ErrConfigNotFound, got %v", err)
+	}
+
+	var notFound *NotFoundError
+	if !errors.As(err, &notFound) || len(notFound.Candidates) != 4 {
+		t.Fatalf("expected NotFoundError with 4 candidates, got %v", err)
+	}
+	if !strings.Contains(err.Error(), filepath.Join(home, ".culebra-test-missing", "config.lua")) {
+		t.Errorf("expected error to list candidates, got %v", err)
+	}
+}
@@ -0,0 +1,327 @@
+package culebra
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Fuabioo/culebra/internal"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// evaluationKey is the registry key holding the evaluation record of a state
+const evaluationKey = "culebra.evaluation"
+
+// Style is the way a config hands its values to culebra
+type Style int
+
+const (
+	// StyleReturn configs return a table, like Neovim configs
+	StyleReturn Style = iota
+	// StyleGlobals configs assign global variables
+	StyleGlobals
+)
+
+func (s Style) String() string {
+	if s == StyleGlobals {
+		return "globals"
+	}
+	return "return"
+}
+
+// Document is the result of LoadDocument: the config data together with what produced it
+type Document struct {
+	Data     map[string]any
+	Sources  []string          // Config file followed by the files loaded with require, in load order
+	Hashes   map[string]string // SHA-256 of each source, hex encoded
+	Duration time.Duration     // Time spent evaluating the Lua code
+	Warnings []Finding         // Messages passed to culebra.warn
+	Style    Style
+	Origins  map[string]Origin // Where each dotted key was last assigned, see Origin
+}
+
+// Origin is the source position of the last assignment to a config key
+type Origin struct {
+	Key  string // Dotted key the position belongs to, a parent of the requested key when it was not tracked
+	File string
+	Line int
+}
+
+func (o Origin) String() string {
+	return fmt.Sprintf("%s:%d", o.File, o.Line)
+}
+
+// Origin returns where a dotted key was last assigned. Keys whose assignment was not tracked,
+// such as keys set through rawset, report the origin of their nearest parent.
+func (d *Document) Origin(key string) (Origin, bool) {
+	return lookupOrigin(d.Origins, key)
+}
+
+// lookupOrigin returns the origin of a dotted key, or of its nearest parent, matching keys
+// case-insensitively like Viper
+func lookupOrigin(origins map[string]Origin, key string) (Origin, bool) {
+	for key != "" {
+		if origin, ok := origins[key]; ok {
+			return origin, true
+		}
+		for path, origin := range origins {
+			if strings.EqualFold(path, key) {
+				return origin, true
+			}
+		}
+		index := strings.LastIndex(key, ".")
+		if index < 0 {
+			break
+		}
+		key = key[:index]
+	}
+	return Origin{}, false
+}
+
+// LoadDocument loads a Lua config like Load and describes how it was produced, so a service
+// can log exactly which configuration it started with. The config and the files it requires are
+// instrumented to record where each key is assigned.
+func LoadDocument(cfg Config) (*Document, error) {
+	cfg.trackOrigins = true
+	start := time.Now()
+	L, table, err := evaluate(cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer L.Close()
+	duration := time.Since(start)
+
+	data, err := cfg.convert(L, table)
+	if err != nil {
+		return nil, err
+	}
+
+	record := evaluationOf(L)
+	return &Document{
+		Data:     data,
+		Sources:  record.sources,
+		Hashes:   record.hashes,
+		Duration: duration,
+		Warnings: checkFindings(L).warnings,
+		Style:    record.style,
+		Origins:  record.keyOrigins(L, table),
+	}, nil
+}
+
+// evaluation records the sources and the style of a config while it is evaluated, and where
+// table keys are assigned when the sources are instrumented
+type evaluation struct {
+	sources    []string
+	hashes     map[string]string
+	style      Style
+	instrument bool
+	origins    map[*lua.LTable]map[lua.LValue]Origin
+}
+
+// openEvaluation installs the evaluation record of a state and replaces the Lua file searcher
+// of require so that required files are recorded too
+func openEvaluation(L *lua.LState, instrument bool) *evaluation {
+	record := &evaluation{
+		hashes:     make(map[string]string),
+		instrument: instrument,
+		origins:    make(map[*lua.LTable]map[lua.LValue]Origin),
+	}
+	ud := L.NewUserData()
+	ud.Value = record
+	L.SetField(L.Get(lua.RegistryIndex), evaluationKey, ud)
+
+	if loaders, ok := L.GetField(L.GetGlobal("package"), "loaders").(*lua.LTable); ok {
+		loaders.RawSetInt(2, L.NewFunction(record.searchLua))
+	}
+	return record
+}
+
+// evaluationOf returns the evaluation record of a state
+func evaluationOf(L *lua.LState) *evaluation {
+	if ud, ok := L.GetField(L.Get(lua.RegistryIndex), evaluationKey).(*lua.LUserData); ok {
+		if record, ok := ud.Value.(*evaluation); ok {
+			return record
+		}
+	}
+	return &evaluation{hashes: make(map[string]string)}
+}
+
+// load reads and compiles a Lua file, recording it as a source
+func (e *evaluation) load(L *lua.LState, path string) (*lua.LFunction, error) {
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, seen := e.hashes[path]; !seen {
+		e.sources = append(e.sources, path)
+	}
+	sum := sha256.Sum256(source)
+	e.hashes[path] = hex.EncodeToString(sum[:])
+
+	// Like LoadFile, ignore a leading shebang line while keeping line numbers
+	if bytes.HasPrefix(source, []byte("#")) {
+		source = append([]byte("--"), source...)
+	}
+	if !e.instrument {
+		return L.Load(bytes.NewReader(source), path)
+	}
+	return e.loadInstrumented(L, source, path)
+}
+
+// loadInstrumented compiles an instrumented chunk and returns a function running it with hooks
+// that record the origins of the keys it assigns in path
+func (e *evaluation) loadInstrumented(L *lua.LState, source []byte, path string) (*lua.LFunction, error) {
+	proto, err := internal.Instrument(bytes.NewReader(source), path)
+	if err != nil {
+		return nil, err
+	}
+	L.Push(L.NewFunctionFromProto(proto))
+	if err := L.PCall(0, 1, nil); err != nil {
+		return nil, err
+	}
+	body := L.Get(-1)
+	L.Pop(1)
+
+	assign := L.NewFunction(func(L *lua.LState) int {
+		object, key, line := L.CheckAny(1), L.CheckAny(2), L.CheckInt(3)
+		L.SetTable(object, key, L.Get(4))
+		if table, ok := object.(*lua.LTable); ok {
+			e.setOrigin(table, key, path, line)
+		}
+		return 0
+	})
+	global := L.NewFunction(func(L *lua.LState) int {
+		name, line := L.CheckString(1), L.CheckInt(2)
+		env := callerEnv(L)
+		L.SetField(env, name, L.Get(3))
+		if table, ok := env.(*lua.LTable); ok {
+			e.setOrigin(table, lua.LString(name), path, line)
+		}
+		return 0
+	})
+	table := L.NewFunction(func(L *lua.LState) int {
+		constructed, line := L.CheckTable(1), L.CheckInt(2)
+		for i := 3; i < L.GetTop(); i += 2 {
+			e.setOrigin(constructed, L.Get(i), path, L.CheckInt(i+1))
+		}
+		// Keys computed at runtime get the line of the constructor
+		constructed.ForEach(func(key, _ lua.LValue) {
+			if _, ok := e.origins[constructed][key]; !ok {
+				e.setOrigin(constructed, key, path, line)
+			}
+		})
+		L.Push(constructed)
+		return 1
+	})
+
+	return L.NewFunction(func(L *lua.LState) int {
+		top := L.GetTop()
+		L.Push(body)
+		L.Push(assign)
+		L.Push(global)
+		L.Push(table)
+		for i := 1; i <= top; i++ {
+			L.Push(L.Get(i))
+		}
+		L.Call(3+top, lua.MultRet)
+		return L.GetTop() - top
+	}), nil
+}
+
+// callerEnv returns the environment of the Lua function calling a hook, which setfenv or module
+// may have replaced, so that instrumented assignments to globals land where the original would
+func callerEnv(L *lua.LState) lua.LValue {
+	if dbg, ok := L.GetStack(1); ok {
+		if fn, err := L.GetInfo("f", dbg, lua.LNil); err == nil {
+			if fn, ok := fn.(*lua.LFunction); ok && !fn.IsG && fn.Env != nil {
+				return fn.Env
+			}
+		}
+	}
+	return L.Get(lua.GlobalsIndex)
+}
+
+// setOrigin records that key of table was assigned at path:line
+func (e *evaluation) setOrigin(table *lua.LTable, key lua.LValue, path string, line int) {
+	if e.origins[table] == nil {
+		e.origins[table] = make(map[lua.LValue]Origin)
+	}
+	e.origins[table][key] = Origin{File: path, Line: line}
+}
+
+// keyOrigins maps the dotted keys of an evaluated config to the origins recorded for them.
+// Top-level keys of a globals-style config are looked up in the global table.
+func (e *evaluation) keyOrigins(L *lua.LState, config *lua.LTable) map[string]Origin {
+	result := make(map[string]Origin)
+	visited := make(map[*lua.LTable]bool)
+
+	var walk func(table, origins *lua.LTable, path string)
+	walk = func(table, origins *lua.LTable, path string) {
+		if visited[table] {
+			return
+		}
+		visited[table] = true
+		internal.ForEachOrdered(table, func(key, value lua.LValue) {
+			keyPath := key.String()
+			if path != "" {
+				keyPath = path + "." + keyPath
+			}
+			if origin, ok := e.origins[origins][key]; ok {
+				origin.Key = keyPath
+				result[keyPath] = origin
+			}
+			if nested, ok := value.(*lua.LTable); ok {
+				walk(nested, nested, keyPath)
+			}
+		})
+	}
+
+	origins := config
+	if e.style == StyleGlobals {
+		origins = L.Get(lua.GlobalsIndex).(*lua.LTable)
+	}
+	walk(config, origins, "")
+	return result
+}
+
+// searchLua is the package.loaders entry finding modules along package.path
+func (e *evaluation) searchLua(L *lua.LState) int {
+	name := L.CheckString(1)
+	path, messages := findModule(L, name)
+	if path == "" {
+		L.Push(lua.LString(messages))
+		return 1
+	}
+
+	fn, err := e.load(L, path)
+	if err != nil {
+		L.RaiseError("%s", err.Error())
+	}
+	L.Push(fn)
+	return 1
+}
+
+// findModule searches package.path for a module and returns its file, or the paths tried
+func findModule(L *lua.LState, name string) (string, string) {
+	searchPath, ok := L.GetField(L.GetGlobal("package"), "path").(lua.LString)
+	if !ok {
+		L.RaiseError("package.path must be a string")
+	}
+
+	name = strings.ReplaceAll(name, ".", string(os.PathSeparator))
+	var messages []string
+	for _, pattern := range strings.Split(string(searchPath), ";") {
+		candidate := strings.ReplaceAll(pattern, "?", name)
+		if _, err := os.Stat(candidate); err != nil {
+			messages = append(messages, fmt.Sprintf("no file '%s'", candidate))
+			continue
+		}
+		return candidate, ""
+	}
+	return "", "\n\t" + strings.Join(messages, "\n\t")
+}
@@ -0,0 +1,174 @@
+package culebra
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadDocument(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Chdir(tmpDir)
+
+	files := map[string]string{
+		"config.lua":     "local db = require(\"lib.db\")\nculebra.warn(\"using defaults\")\nreturn { database = db }\n",
+		"lib/db.lua":     "return { host = require(\"lib.common\").host }\n",
+		"lib/common.lua": "#!/usr/bin/env lua\nreturn { host = \"localhost\" }\n",
+	}
+	for name, content := range files {
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	doc, err := LoadDocument(Config{FilePath: "config.lua"})
+	if err != nil {
+		t.Fatalf("LoadDocument failed: %v", err)
+	}
+
+	expectedData := map[string]any{"database": map[string]any{"host": "localhost"}}
+	if !reflect.DeepEqual(doc.Data, expectedData) {
+		t.Errorf("Expected %v, got %v", expectedData, doc.Data)
+	}
+
+	expectedSources := []string{"config.lua", "./lib/db.lua", "./lib/common.lua"}
+	if !reflect.DeepEqual(doc.Sources, expectedSources) {
+		t.Errorf("Expected sources %v, got %v", expectedSources, doc.Sources)
+	}
+	sum := sha256.Sum256([]byte(files["config.lua"]))
+	if doc.Hashes["config.lua"] != hex.EncodeToString(sum[:]) {
+		t.Errorf("Unexpected hash %s", doc.Hashes["config.lua"])
+	}
+	if len(doc.Hashes) != 3 {
+		t.Errorf("Expected a hash per source, got %v", doc.Hashes)
+	}
+
+	if doc.Style != StyleReturn {
+		t.Errorf("Expected return style, got %s", doc.Style)
+	}
+	if len(doc.Warnings) != 1 || doc.Warnings[0].Line != 2 {
+		t.Errorf("Expected warning at line 2, got %v", doc.Warnings)
+	}
+	if doc.Duration <= 0 {
+		t.Errorf("Expected evaluation duration, got %s", doc.Duration)
+	}
+}
+
+func TestLoadDocumentGlobalsStyle(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "test.lua")
+	if err := os.WriteFile(configFile, []byte(`name = "app"`), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	doc, err := LoadDocument(Config{FilePath: configFile})
+	if err != nil {
+		t.Fatalf("LoadDocument failed: %v", err)
+	}
+	if doc.Style != StyleGlobals || doc.Style.String() != "globals" {
+		t.Errorf("Expected globals style, got %s", doc.Style)
+	}
+	if doc.Data["name"] != "app" {
+		t.Errorf("Expected data, got %v", doc.Data)
+	}
+}
+
+func TestLoadDocumentMatchesLoad(t *testing.T) {
+	sources := map[string]string{
+		"setfenv": "local m = {}\nlocal function f() y = 5 end\nsetfenv(f, m)\nf()\nz = m.y\n",
+		"module":  "package.loaded.settings = nil\nlocal function define() module(\"settings\") level = \"info\" end\ndefine()\nlevel = settings.level .. \"!\"\nsettings = nil\n",
+	}
+
+	for name, source := range sources {
+		t.Run(name, func(t *testing.T) {
+			configFile := filepath.Join(t.TempDir(), "test.lua")
+			if err := os.WriteFile(configFile, []byte(source), 0644); err != nil {
+				t.Fatalf("Failed to write test config: %v", err)
+			}
+
+			data, err := Load(Config{FilePath: configFile})
+			if err != nil {
+				t.Fatalf("Load failed: %v", err)
+			}
+			doc, err := LoadDocument(Config{FilePath: configFile})
+			if err != nil {
+				t.Fatalf("LoadDocument failed: %v", err)
+			}
+			if !reflect.DeepEqual(doc.Data, data) {
+				t.Errorf("Expected LoadDocument data %v to equal Load data %v", doc.Data, data)
+			}
+		})
+	}
+}
+
+func TestDocumentOrigin(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Chdir(tmpDir)
+
+	files := map[string]string{
+		"config.lua": `local defaults = require("defaults")
+database = defaults.database
+database.host = "db.internal"
+servers = {
+    "a",
+    "b",
+}
+local function port() return 6543 end
+database.port = port()
+`,
+		"defaults.lua": `local M = {}
+M.database = {
+    host = "localhost",
+    port = 5432,
+    user = "app",
+}
+return M
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	doc, err := LoadDocument(Config{FilePath: "config.lua", ConvertArrays: true})
+	if err != nil {
+		t.Fatalf("LoadDocument failed: %v", err)
+	}
+
+	tests := []struct {
+		key      string
+		expected Origin
+	}{
+		{"database", Origin{Key: "database", File: "config.lua", Line: 2}},
+		{"database.host", Origin{Key: "database.host", File: "config.lua", Line: 3}},
+		{"database.port", Origin{Key: "database.port", File: "config.lua", Line: 9}},
+		{"database.user", Origin{Key: "database.user", File: "./defaults.lua", Line: 5}},
+		{"servers.2", Origin{Key: "servers.2", File: "config.lua", Line: 6}},
+		{"Database.Host", Origin{Key: "database.host", File: "config.lua", Line: 3}},
+		{"database.user.missing", Origin{Key: "database.user", File: "./defaults.lua", Line: 5}},
+	}
+
+	for _, tt := range tests {
+		origin, ok := doc.Origin(tt.key)
+		if !ok {
+			t.Errorf("Expected origin for %s", tt.key)
+			continue
+		}
+		if origin != tt.expected {
+			t.Errorf("Expected %s to come from %+v, got %+v", tt.key, tt.expected, origin)
+		}
+	}
+
+	if _, ok := doc.Origin("unknown"); ok {
+		t.Error("Expected no origin for unknown key")
+	}
+	if doc.Data["database"].(map[string]any)["port"] != float64(6543) {
+		t.Errorf("Expected instrumented config to evaluate normally, got %v", doc.Data)
+	}
+}
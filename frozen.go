@@ -0,0 +1,10 @@
+package culebra
+
+import "github.com/Fuabioo/culebra/internal"
+
+// Frozen marks a value passed in Config.Globals as read-only. The config can read it, including
+// nested tables, but assigning the global or any of its keys raises a Lua error naming the key.
+// Frozen globals are inputs to the script and are not part of the loaded configuration.
+func Frozen(value any) any {
+	return internal.ReadOnly{Value: value}
+}
@@ -0,0 +1,64 @@
+package culebra
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFrozenGlobals(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	globals := map[string]any{
+		"env": Frozen(map[string]any{
+			"region": "us-east-1",
+			"network": map[string]any{
+				"zones": []any{"a", "b"},
+			},
+		}),
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		errKey  string
+	}{
+		{"read nested", `region = env.region .. "-" .. env.network.zones[2]`, ""},
+		{"assign key", `env.region = "eu-west-1"`, "'env.region'"},
+		{"assign nested key", `env.network.zones[1] = "c"`, "'env.network.zones.1'"},
+		{"add key", `env.network.extra = true`, "'env.network.extra'"},
+		{"assign global", `env = {}`, "'env'"},
+		{"rawset through metatable", `setmetatable(env, nil)`, "protected metatable"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configFile := filepath.Join(tmpDir, strings.ReplaceAll(tt.name, " ", "_")+".lua")
+			if err := os.WriteFile(configFile, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("Failed to write test config: %v", err)
+			}
+
+			result, err := Load(Config{FilePath: configFile, Globals: globals})
+			if tt.errKey == "" {
+				if err != nil {
+					t.Fatalf("Load failed: %v", err)
+				}
+				if result["region"] != "us-east-1-b" {
+					t.Errorf("Expected region from frozen global, got %v", result["region"])
+				}
+				if _, ok := result["env"]; ok {
+					t.Error("Expected frozen global to be excluded from the result")
+				}
+				return
+			}
+
+			if err == nil {
+				t.Fatal("Expected error modifying frozen global")
+			}
+			if !strings.Contains(err.Error(), tt.errKey) {
+				t.Errorf("Expected error to mention %s, got %v", tt.errKey, err)
+			}
+		})
+	}
+}
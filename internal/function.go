@@ -0,0 +1,256 @@
+package internal
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+
+	"github.com/yuin/gopher-lua"
+)
+
+var (
+	errorType      = reflect.TypeOf((*error)(nil)).Elem()
+	lgFunctionType = reflect.TypeOf(lua.LGFunction(nil))
+	anyType        = reflect.TypeOf((*any)(nil)).Elem()
+)
+
+// convertFunc exposes a Go function to Lua. Functions with the lua.LGFunction signature are
+// registered as-is; any other function is called through reflection, converting Lua arguments
+// to the parameter types and the results back to Lua. A trailing non-nil error result is
+// raised as a Lua error.
+func (c *goConverter) convertFunc(rv reflect.Value, path string) (lua.LValue, error) {
+	if rv.IsNil() {
+		return lua.LNil, nil
+	}
+
+	if rv.Type().ConvertibleTo(lgFunctionType) {
+		return c.L.NewFunction(rv.Convert(lgFunctionType).Interface().(lua.LGFunction)), nil
+	}
+
+	return c.L.NewFunction(reflectFunction(rv, displayPath(path), nil)), nil
+}
+
+// reflectFunction wraps a Go function in an LGFunction. When self is not nil and passed as the
+// first argument, as with method calls using colon syntax, it is dropped before conversion.
+func reflectFunction(fn reflect.Value, name string, self lua.LValue) lua.LGFunction {
+	fnType := fn.Type()
+
+	return func(L *lua.LState) int {
+		if self != nil && L.GetTop() > 0 && L.Get(1) == self {
+			L.Remove(1)
+		}
+
+		args, err := luaArgs(L, fnType)
+		if err != nil {
+			L.RaiseError("%s: %v", name, err)
+		}
+
+		results := fn.Call(args)
+		if n := fnType.NumOut(); n > 0 && fnType.Out(n-1) == errorType {
+			if errValue := results[n-1]; !errValue.IsNil() {
+				L.RaiseError("%s: %v", name, errValue.Interface())
+			}
+			results = results[:n-1]
+		}
+
+		for _, result := range results {
+			value, err := newGoConverter(L).convert(result, name)
+			if err != nil {
+				L.RaiseError("%s: %v", name, err)
+			}
+			L.Push(value)
+		}
+		return len(results)
+	}
+}
+
+// luaArgs converts the arguments on the Lua stack to the parameter types of a Go function.
+// Missing arguments become zero values and extra arguments to non-variadic functions are ignored.
+func luaArgs(L *lua.LState, fnType reflect.Type) ([]reflect.Value, error) {
+	count := fnType.NumIn()
+	if fnType.IsVariadic() {
+		count = max(L.GetTop(), count-1)
+	}
+
+	args := make([]reflect.Value, 0, count)
+	for i := 0; i < count; i++ {
+		var paramType reflect.Type
+		if fnType.IsVariadic() && i >= fnType.NumIn()-1 {
+			paramType = fnType.In(fnType.NumIn() - 1).Elem()
+		} else {
+			paramType = fnType.In(i)
+		}
+
+		arg, err := LuaToReflect(L.Get(i+1), paramType)
+		if err != nil {
+			return nil, fmt.Errorf("bad argument #%d: %w", i+1, err)
+		}
+		args = append(args, arg)
+	}
+	return args, nil
+}
+
+// LuaToReflect converts a Lua value into a Go value of the given type. Nil becomes the zero value.
+func LuaToReflect(lv lua.LValue, t reflect.Type) (reflect.Value, error) {
+	if ud, ok := lv.(*lua.LUserData); ok {
+		if exposed, ok := ud.Value.(*exposedValue); ok && exposed.value.Type().AssignableTo(t) {
+			return exposed.value, nil
+		}
+	}
+	if t != anyType && reflect.TypeOf(lv).AssignableTo(t) {
+		return reflect.ValueOf(lv), nil
+	}
+	if lv == lua.LNil {
+		return reflect.Zero(t), nil
+	}
+	if t == anyType {
+		value := LuaToGoWithConfig(lv, true)
+		if value == nil {
+			return reflect.Zero(t), nil
+		}
+		return reflect.ValueOf(value), nil
+	}
+
+	switch t {
+	case durationType:
+		duration, err := time.ParseDuration(lv.String())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(duration), nil
+	case timeType:
+		parsed, err := time.Parse(time.RFC3339Nano, lv.String())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(parsed), nil
+	}
+
+	result := reflect.New(t).Elem()
+
+	switch t.Kind() {
+	case reflect.Bool:
+		b, ok := lv.(lua.LBool)
+		if !ok {
+			return reflect.Value{}, typeMismatch("boolean", lv)
+		}
+		result.SetBool(bool(b))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, ok := lv.(lua.LNumber)
+		if !ok || float64(n) != math.Trunc(float64(n)) {
+			return reflect.Value{}, typeMismatch("integer", lv)
+		}
+		if float64(n) < math.MinInt64 || float64(n) >= math.MaxInt64 || result.OverflowInt(int64(n)) {
+			return reflect.Value{}, outOfRange(n, t)
+		}
+		result.SetInt(int64(n))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, ok := lv.(lua.LNumber)
+		if !ok || float64(n) != math.Trunc(float64(n)) {
+			return reflect.Value{}, typeMismatch("non-negative integer", lv)
+		}
+		if n < 0 || float64(n) >= math.MaxUint64 || result.OverflowUint(uint64(n)) {
+			return reflect.Value{}, outOfRange(n, t)
+		}
+		result.SetUint(uint64(n))
+	case reflect.Float32, reflect.Float64:
+		n, ok := lv.(lua.LNumber)
+		if !ok {
+			return reflect.Value{}, typeMismatch("number", lv)
+		}
+		if result.OverflowFloat(float64(n)) {
+			return reflect.Value{}, outOfRange(n, t)
+		}
+		result.SetFloat(float64(n))
+	case reflect.String:
+		switch v := lv.(type) {
+		case lua.LString:
+			result.SetString(string(v))
+		case lua.LNumber:
+			result.SetString(v.String())
+		default:
+			return reflect.Value{}, typeMismatch("string", lv)
+		}
+	case reflect.Pointer:
+		elem, err := LuaToReflect(lv, t.Elem())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		result = reflect.New(t.Elem())
+		result.Elem().Set(elem)
+	case reflect.Slice:
+		table, ok := lv.(*lua.LTable)
+		if !ok {
+			return reflect.Value{}, typeMismatch("table", lv)
+		}
+		result = reflect.MakeSlice(t, 0, table.Len())
+		for i := 1; i <= table.Len(); i++ {
+			elem, err := LuaToReflect(table.RawGetInt(i), t.Elem())
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("index %d: %w", i, err)
+			}
+			result = reflect.Append(result, elem)
+		}
+	case reflect.Map:
+		table, ok := lv.(*lua.LTable)
+		if !ok {
+			return reflect.Value{}, typeMismatch("table", lv)
+		}
+		result = reflect.MakeMap(t)
+		var mapErr error
+		table.ForEach(func(key, value lua.LValue) {
+			if mapErr != nil {
+				return
+			}
+			goKey, err := LuaToReflect(key, t.Key())
+			if err != nil {
+				mapErr = fmt.Errorf("key %s: %w", key, err)
+				return
+			}
+			goValue, err := LuaToReflect(value, t.Elem())
+			if err != nil {
+				mapErr = fmt.Errorf("field %s: %w", key, err)
+				return
+			}
+			result.SetMapIndex(goKey, goValue)
+		})
+		if mapErr != nil {
+			return reflect.Value{}, mapErr
+		}
+	case reflect.Struct:
+		table, ok := lv.(*lua.LTable)
+		if !ok {
+			return reflect.Value{}, typeMismatch("table", lv)
+		}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name, _, skip := fieldName(field)
+			if !field.IsExported() || skip {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			value, err := LuaToReflect(table.RawGetString(name), field.Type)
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("field %s: %w", name, err)
+			}
+			result.Field(i).Set(value)
+		}
+	default:
+		return reflect.Value{}, fmt.Errorf("unsupported parameter type %s", t)
+	}
+
+	return result, nil
+}
+
+// outOfRange describes a Lua number that does not fit the expected Go numeric type
+func outOfRange(n lua.LNumber, t reflect.Type) error {
+	return fmt.Errorf("number %s out of range for %s", n, t)
+}
+
+// typeMismatch describes a Lua value that does not fit the expected Go type
+func typeMismatch(expected string, lv lua.LValue) error {
+	return fmt.Errorf("%s expected, got %s", expected, lv.Type())
+}
@@ -0,0 +1,123 @@
+package internal
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/yuin/gopher-lua"
+)
+
+func TestGoToLuaFunctions(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	globals := map[string]any{
+		"raw": lua.LGFunction(func(L *lua.LState) int {
+			L.Push(lua.LString("raw:" + L.CheckString(1)))
+			return 1
+		}),
+		"plain": func(L *lua.LState) int {
+			L.Push(lua.LNumber(L.CheckInt(1) * 2))
+			return 1
+		},
+		"add": func(a, b int) int {
+			return a + b
+		},
+		"join": func(sep string, parts ...string) string {
+			return strings.Join(parts, sep)
+		},
+		"secret": func(path string) (string, error) {
+			if path == "db/password" {
+				return "hunter2", nil
+			}
+			return "", errors.New("secret not found: " + path)
+		},
+		"region": func() (string, int) {
+			return "us-east-1", 3
+		},
+		"sum": func(values []float64) float64 {
+			total := 0.0
+			for _, v := range values {
+				total += v
+			}
+			return total
+		},
+		"describe": func(value any) string {
+			if m, ok := value.(map[string]any); ok {
+				return m["name"].(string)
+			}
+			return "unknown"
+		},
+	}
+
+	for name, value := range globals {
+		lv, err := GoToLuaNamed(L, name, value)
+		if err != nil {
+			t.Fatalf("GoToLua(%s) failed: %v", name, err)
+		}
+		if lv.Type() != lua.LTFunction {
+			t.Fatalf("GoToLua(%s) type = %v, want function", name, lv.Type())
+		}
+		L.SetGlobal(name, lv)
+	}
+
+	if err := L.DoString(`
+		assert(raw("x") == "raw:x", "raw LGFunction")
+		assert(plain(21) == 42, "plain LGFunction signature")
+		assert(add(1, 2) == 3, "typed arguments")
+		assert(join("-", "a", "b", "c") == "a-b-c", "variadic arguments")
+		assert(secret("db/password") == "hunter2", "value with nil error")
+		local name, zones = region()
+		assert(name == "us-east-1" and zones == 3, "multiple results")
+		assert(sum({1, 2, 3.5}) == 6.5, "slice argument")
+		assert(describe({name = "culebra"}) == "culebra", "any argument")
+	`); err != nil {
+		t.Fatalf("Calling Go functions failed: %v", err)
+	}
+
+	err := L.DoString(`secret("missing")`)
+	if err == nil || !strings.Contains(err.Error(), "secret not found: missing") {
+		t.Errorf("Expected Go error to surface as Lua error, got %v", err)
+	}
+
+	err = L.DoString(`add("one", 2)`)
+	if err == nil || !strings.Contains(err.Error(), "bad argument #1") {
+		t.Errorf("Expected argument conversion error, got %v", err)
+	}
+
+	err = L.DoString(`add(1.5, 2)`)
+	if err == nil || !strings.Contains(err.Error(), "integer expected") {
+		t.Errorf("Expected integer conversion error, got %v", err)
+	}
+}
+
+func TestGoToLuaFunctionsOutOfRange(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	globals := map[string]any{
+		"small":    func(n int8) int8 { return n },
+		"count":    func(n uint) uint { return n },
+		"big":      func(n int64) int64 { return n },
+		"fraction": func(n float32) float32 { return n },
+	}
+	for name, value := range globals {
+		lv, err := GoToLuaNamed(L, name, value)
+		if err != nil {
+			t.Fatalf("GoToLua(%s) failed: %v", name, err)
+		}
+		L.SetGlobal(name, lv)
+	}
+
+	if err := L.DoString(`assert(small(-128) == -128 and count(0) == 0)`); err != nil {
+		t.Fatalf("Expected numbers in range to convert, got %v", err)
+	}
+
+	for _, script := range []string{`small(300)`, `count(-1)`, `big(1e300)`, `big(math.huge)`, `fraction(1e300)`} {
+		err := L.DoString(script)
+		if err == nil || !strings.Contains(err.Error(), "bad argument #1") || !strings.Contains(err.Error(), "out of range") {
+			t.Errorf("Expected out of range argument error for %s, got %v", script, err)
+		}
+	}
+}
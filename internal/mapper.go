@@ -1,122 +1,486 @@
 package internal
 
 import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+
 	"github.com/yuin/gopher-lua"
 )
 
+// FunctionPolicy controls how functions, userdata and threads are converted to Go
+type FunctionPolicy int
+
+const (
+	// FunctionsDrop omits functions, userdata and threads from the result
+	FunctionsDrop FunctionPolicy = iota
+	// FunctionsError fails the conversion, naming the key path of the value
+	FunctionsError
+	// FunctionsKeep keeps their string form, such as "function: 0xc000123"
+	FunctionsKeep
+)
+
+// MixedTablePolicy controls how tables that are neither proper arrays nor plain maps are converted
+// when array conversion is enabled
+type MixedTablePolicy int
+
+const (
+	// MixedAsMap converts mixed and sparse tables to maps with string keys such as "1"
+	MixedAsMap MixedTablePolicy = iota
+	// MixedError fails the conversion, naming the key path of the table
+	MixedError
+	// MixedSplit converts mixed tables to maps holding the integer keys as a slice under ArrayPartKey;
+	// tables with more than MaxArrayHoles holes become maps
+	MixedSplit
+	// MixedFillHoles converts sparse arrays to slices with nil in the holes; mixed tables and arrays
+	// with more than MaxArrayHoles holes become maps
+	MixedFillHoles
+)
+
+// ArrayPartKey holds the integer-keyed part of a table split by MixedSplit
+const ArrayPartKey = "[]"
+
+// MaxArrayHoles is the number of holes a sparse table may have beyond its item count before
+// MixedSplit and MixedFillHoles convert it to a map instead of a slice with nil in the holes
+const MaxArrayHoles = 1024
+
+// Options configures the conversion of Lua values to Go values
+type Options struct {
+	ConvertArrays bool           // Convert Lua arrays to Go slices instead of maps
+	Functions     FunctionPolicy // How functions, userdata and threads are converted
+	PrivatePrefix string         // Top-level string keys starting with this prefix are omitted; empty keeps every key
+
+	MixedTables MixedTablePolicy // How mixed and sparse tables are converted when ConvertArrays is set
+	TypedKeys   bool             // Convert nested tables to map[any]any keeping number and boolean keys
+	Ordered     bool             // Convert nested tables to *OrderedMap keeping declaration order, takes precedence over TypedKeys
+
+	ResolveMetatables bool // Include keys inherited through __index tables and listed by __pairs
+	MetatableDepth    int  // Metatables followed on one key path, DefaultMetatableDepth when zero
+}
+
+// luaConverter converts Lua values into Go values according to its options
+type luaConverter struct {
+	opts  Options
+	L     *lua.LState // Calls __pairs metamethods when resolving metatables
+	depth int         // Metatables resolved on the current conversion path
+
+	// visiting holds the tables on the current conversion path to detect cycles
+	visiting map[*lua.LTable]bool
+}
+
+// newLuaConverter returns a converter calling metamethods with L. Without a state, one is
+// created when opts.ResolveMetatables needs it; the returned function closes it.
+func newLuaConverter(L *lua.LState, opts Options) (*luaConverter, func()) {
+	visiting := make(map[*lua.LTable]bool)
+	if L != nil || !opts.ResolveMetatables {
+		return &luaConverter{opts: opts, L: L, visiting: visiting}, func() {}
+	}
+	L = lua.NewState(lua.Options{SkipOpenLibs: true})
+	return &luaConverter{opts: opts, L: L, visiting: visiting}, L.Close
+}
+
+// enter marks a table as being converted and reports false when it already is, meaning the
+// table contains itself. The returned function unmarks it.
+func (c *luaConverter) enter(table *lua.LTable) (func(), bool) {
+	if c.visiting[table] {
+		return nil, false
+	}
+	c.visiting[table] = true
+	return func() { delete(c.visiting, table) }, true
+}
+
 func LuaToGo(lv lua.LValue) any {
 	return LuaToGoWithConfig(lv, false)
 }
 
 func LuaToGoWithConfig(lv lua.LValue, convertArrays bool) any {
+	value, _ := Convert(lv, Options{ConvertArrays: convertArrays, Functions: FunctionsKeep})
+	return value
+}
+
+// Convert converts a Lua value into a Go value according to opts. Values dropped by the
+// function policy convert to nil.
+func Convert(lv lua.LValue, opts Options) (any, error) {
+	c, done := newLuaConverter(nil, opts)
+	defer done()
+	value, _, err := c.convert(lv, "")
+	return value, err
+}
+
+// ConvertTable converts the entries of a Lua table into a map according to opts
+func ConvertTable(table *lua.LTable, opts Options) (map[string]any, error) {
+	return ConvertTableWithState(nil, table, opts)
+}
+
+// ConvertTableWithState is like ConvertTable but uses L to call __pairs metamethods
+// when opts.ResolveMetatables is set
+func ConvertTableWithState(L *lua.LState, table *lua.LTable, opts Options) (map[string]any, error) {
+	c, done := newLuaConverter(L, opts)
+	defer done()
+	leave, _ := c.enter(table)
+	defer leave()
+	resolved, _, err := c.resolveMetatable(table, "")
+	if err != nil {
+		return nil, err
+	}
+	return c.tableToMap(resolved, "")
+}
+
+// ConvertOrderedTable is like ConvertTableWithState but returns an OrderedMap and converts
+// nested tables to ordered maps as well
+func ConvertOrderedTable(L *lua.LState, table *lua.LTable, opts Options) (*OrderedMap, error) {
+	opts.Ordered = true
+	c, done := newLuaConverter(L, opts)
+	defer done()
+	leave, _ := c.enter(table)
+	defer leave()
+	resolved, _, err := c.resolveMetatable(table, "")
+	if err != nil {
+		return nil, err
+	}
+	return c.tableToOrdered(resolved, "")
+}
+
+// convert returns the Go value for lv and whether it should be kept in its parent
+func (c *luaConverter) convert(lv lua.LValue, path string) (any, bool, error) {
 	switch v := lv.(type) {
 	case *lua.LNilType:
-		return nil
+		return nil, true, nil
 	case lua.LBool:
-		return bool(v)
+		return bool(v), true, nil
 	case lua.LNumber:
-		return float64(v)
+		return float64(v), true, nil
 	case lua.LString:
-		return string(v)
+		return string(v), true, nil
 	case *lua.LTable:
-		if convertArrays && isLuaArray(v) {
-			return luaTableToGoSlice(v, convertArrays)
+		if backing, ok := readOnlyBacking(v); ok {
+			v = backing
+		}
+		leave, ok := c.enter(v)
+		if !ok {
+			return nil, false, fmt.Errorf("cyclic table at %s", displayPath(path))
+		}
+		defer leave()
+		resolved, ok, err := c.resolveMetatable(v, path)
+		if err != nil {
+			return nil, false, err
+		}
+		if ok {
+			c.depth++
+			defer func() { c.depth-- }()
+			v = resolved
 		}
-		return luaTableToGoMap(v, convertArrays)
+		switch tableMarker(v) {
+		case arrayMarker:
+			result, err := c.tableToSlice(v, path)
+			return result, true, err
+		case objectMarker:
+			result, err := c.tableToObject(v, path)
+			return result, true, err
+		}
+		if c.opts.ConvertArrays {
+			shape := shapeOf(v)
+			if shape.isArray() {
+				result, err := c.tableToSlice(v, path)
+				return result, true, err
+			}
+			if shape.isIrregular() {
+				result, err := c.convertIrregular(v, shape, path)
+				return result, true, err
+			}
+		}
+		result, err := c.tableToObject(v, path)
+		return result, true, err
+	case *lua.LUserData:
+		if isNull(v) {
+			return nil, true, nil
+		}
+		return c.convertOpaque(v, path)
 	default:
-		return v.String()
-	}
-}
-
-func GoToLua(L *lua.LState, value any) lua.LValue {
-	switch v := value.(type) {
-	case nil:
-		return lua.LNil
-	case bool:
-		return lua.LBool(v)
-	case int:
-		return lua.LNumber(v)
-	case int64:
-		return lua.LNumber(v)
-	case float64:
-		return lua.LNumber(v)
-	case string:
-		return lua.LString(v)
-	case map[string]any:
-		return goMapToLuaTable(L, v)
-	case []any:
-		return goSliceToLuaTable(L, v)
+		return c.convertOpaque(v, path)
+	}
+}
+
+// convertOpaque applies the function policy to functions, userdata and threads
+func (c *luaConverter) convertOpaque(v lua.LValue, path string) (any, bool, error) {
+	switch c.opts.Functions {
+	case FunctionsKeep:
+		return v.String(), true, nil
+	case FunctionsError:
+		return nil, false, fmt.Errorf("cannot convert %s value at %s", v.Type(), displayPath(path))
 	default:
-		return lua.LString(v.(string))
+		return nil, false, nil
 	}
 }
 
-func luaTableToGoMap(table *lua.LTable, convertArrays bool) map[string]any {
-	result := make(map[string]any)
-	table.ForEach(func(key, value lua.LValue) {
-		result[key.String()] = LuaToGoWithConfig(value, convertArrays)
+// GoToLua converts a Go value into a Lua value. Values of any numeric kind become numbers,
+// slices and arrays become sequences, maps and structs become tables and pointers are followed.
+// Unsupported kinds such as channels produce an error naming the offending path.
+func GoToLua(L *lua.LState, value any) (lua.LValue, error) {
+	return newGoConverter(L).convert(reflect.ValueOf(value), "")
+}
+
+// GoToLuaNamed converts a Go value like GoToLua, using name as the root of the dotted paths
+// reported in conversion errors and read-only violations
+func GoToLuaNamed(L *lua.LState, name string, value any) (lua.LValue, error) {
+	return newGoConverter(L).convert(reflect.ValueOf(value), name)
+}
+
+// isPrivate reports whether a key is omitted by the private prefix convention, which only applies
+// to the top-level keys where helper globals live so nested data like { _id = 1 } is kept
+func (c *luaConverter) isPrivate(key lua.LValue, path string) bool {
+	name, ok := key.(lua.LString)
+	return ok && path == "" && c.opts.PrivatePrefix != "" && strings.HasPrefix(string(name), c.opts.PrivatePrefix)
+}
+
+// tableToObject converts a nested table to a map[string]any, to a map[any]any with TypedKeys or
+// to an *OrderedMap with Ordered
+func (c *luaConverter) tableToObject(table *lua.LTable, path string, filter ...func(lua.LValue) bool) (any, error) {
+	if c.opts.Ordered {
+		result, err := c.tableToOrdered(table, path, filter...)
+		if err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+	if !c.opts.TypedKeys {
+		result, err := c.tableToMap(table, path, filter...)
+		if err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+
+	result := make(map[any]any)
+	err := c.entries(table, path, filter, func(key lua.LValue, value any) error {
+		typed, err := typedKey(key)
+		if err != nil {
+			return fmt.Errorf("%w at %s", err, displayPath(path))
+		}
+		result[typed] = value
+		return nil
 	})
-	return result
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
 }
 
-// isLuaArray checks if a Lua table is an array (sequential integer keys starting from 1)
-func isLuaArray(table *lua.LTable) bool {
-	length := table.Len()
-	if length == 0 {
-		return false
+// tableToMap converts the entries of a table to a map with string keys, optionally limited to keys
+// matching filter, and fails when two keys such as 1 and "1" share the same string form
+func (c *luaConverter) tableToMap(table *lua.LTable, path string, filter ...func(lua.LValue) bool) (map[string]any, error) {
+	result := make(map[string]any)
+	origins := make(map[string]lua.LValue)
+	err := c.entries(table, path, filter, func(key lua.LValue, value any) error {
+		name, err := stringKey(origins, key, path)
+		if err != nil {
+			return err
+		}
+		result[name] = value
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
+	return result, nil
+}
 
-	// Check if all keys from 1 to length exist and are the only keys
-	for i := 1; i <= length; i++ {
-		if table.RawGetInt(i) == lua.LNil {
-			return false
+// tableToOrdered converts the entries of a table to an OrderedMap, detecting colliding keys like tableToMap
+func (c *luaConverter) tableToOrdered(table *lua.LTable, path string, filter ...func(lua.LValue) bool) (*OrderedMap, error) {
+	result := NewOrderedMap()
+	origins := make(map[string]lua.LValue)
+	err := c.entries(table, path, filter, func(key lua.LValue, value any) error {
+		name, err := stringKey(origins, key, path)
+		if err != nil {
+			return err
 		}
+		result.Set(name, value)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
+	return result, nil
+}
 
-	// Check that there are no other keys
-	hasOtherKeys := false
-	table.ForEach(func(key, value lua.LValue) {
-		if keyType := key.Type(); keyType != lua.LTNumber {
-			hasOtherKeys = true
+// entries converts the values of a table and passes every kept entry to add in declaration order
+func (c *luaConverter) entries(table *lua.LTable, path string, filter []func(lua.LValue) bool, add func(lua.LValue, any) error) error {
+	var err error
+	ForEachOrdered(table, func(key, value lua.LValue) {
+		if err != nil || c.isPrivate(key, path) {
 			return
 		}
-		if keyNum, ok := key.(lua.LNumber); ok {
-			keyInt := int(keyNum)
-			if keyInt < 1 || keyInt > length || float64(keyInt) != float64(keyNum) {
-				hasOtherKeys = true
+		for _, include := range filter {
+			if !include(key) {
 				return
 			}
 		}
+		converted, keep, convertErr := c.convert(value, joinPath(path, key))
+		if convertErr != nil {
+			err = convertErr
+			return
+		}
+		if keep {
+			err = add(key, converted)
+		}
 	})
+	return err
+}
 
-	return !hasOtherKeys
+// stringKey returns the string form of key, failing when another key in origins has the same form
+func stringKey(origins map[string]lua.LValue, key lua.LValue, path string) (string, error) {
+	name := key.String()
+	if other, exists := origins[name]; exists {
+		return "", fmt.Errorf("keys %s and %s at %s both convert to %q", describeKey(other), describeKey(key), displayPath(path), name)
+	}
+	origins[name] = key
+	return name, nil
 }
 
-// luaTableToGoSlice converts a Lua array table to a Go slice
-func luaTableToGoSlice(table *lua.LTable, convertArrays bool) []any {
-	length := table.Len()
-	result := make([]any, length)
+// typedKey converts a Lua key to a comparable Go value
+func typedKey(key lua.LValue) (any, error) {
+	switch k := key.(type) {
+	case lua.LString:
+		return string(k), nil
+	case lua.LNumber:
+		return float64(k), nil
+	case lua.LBool:
+		return bool(k), nil
+	default:
+		return nil, fmt.Errorf("unsupported %s key", key.Type())
+	}
+}
 
-	for i := 1; i <= length; i++ {
-		value := table.RawGetInt(i)
-		result[i-1] = LuaToGoWithConfig(value, convertArrays)
+// describeKey formats a key with its Lua type for error messages
+func describeKey(key lua.LValue) string {
+	if key.Type() == lua.LTString {
+		return fmt.Sprintf("%q", key.String())
+	}
+	return fmt.Sprintf("%s (%s)", key.String(), key.Type())
+}
+
+// tableShape summarizes the keys of a table
+type tableShape struct {
+	indexCount int // Positive integer keys
+	maxIndex   int // Largest positive integer key
+	otherCount int // Keys of any other kind
+}
+
+func shapeOf(table *lua.LTable) tableShape {
+	var shape tableShape
+	table.ForEach(func(key, _ lua.LValue) {
+		if index, ok := arrayIndex(key); ok {
+			shape.indexCount++
+			shape.maxIndex = max(shape.maxIndex, index)
+			return
+		}
+		shape.otherCount++
+	})
+	return shape
+}
+
+// isArray reports whether the keys are exactly 1..n
+func (s tableShape) isArray() bool {
+	return s.indexCount > 0 && s.otherCount == 0 && s.maxIndex == s.indexCount
+}
+
+// isIrregular reports whether the table has integer keys with holes or mixed with other keys
+func (s tableShape) isIrregular() bool {
+	return s.indexCount > 0 && !s.isArray()
+}
+
+// fitsSlice reports whether the integer keys are dense enough to be converted to a slice holding
+// nil in the holes, so that a key such as [1e9] cannot allocate a huge slice
+func (s tableShape) fitsSlice() bool {
+	return s.maxIndex-s.indexCount <= max(s.indexCount, MaxArrayHoles)
+}
+
+// arrayIndex returns the position of a key that is a positive integer
+func arrayIndex(key lua.LValue) (int, bool) {
+	number, ok := key.(lua.LNumber)
+	if !ok || number < 1 || float64(number) >= math.MaxInt || float64(number) != math.Trunc(float64(number)) {
+		return 0, false
+	}
+	return int(number), true
+}
+
+// convertIrregular converts a sparse or mixed table according to the mixed table policy
+func (c *luaConverter) convertIrregular(table *lua.LTable, shape tableShape, path string) (any, error) {
+	switch c.opts.MixedTables {
+	case MixedError:
+		if shape.otherCount > 0 {
+			return nil, fmt.Errorf("table at %s mixes array items with other keys", displayPath(path))
+		}
+		return nil, fmt.Errorf("array at %s has holes (largest index %d, %d items)", displayPath(path), shape.maxIndex, shape.indexCount)
+	case MixedSplit:
+		if !shape.fitsSlice() {
+			break
+		}
+		if table.RawGetString(ArrayPartKey) != lua.LNil {
+			return nil, fmt.Errorf("table at %s already has a %q key", displayPath(path), ArrayPartKey)
+		}
+		result, err := c.tableToObject(table, path, isNonIndex)
+		if err != nil {
+			return nil, err
+		}
+		items, err := c.indexedToSlice(table, shape.maxIndex, path)
+		if err != nil {
+			return nil, err
+		}
+		switch object := result.(type) {
+		case map[string]any:
+			object[ArrayPartKey] = items
+		case map[any]any:
+			object[ArrayPartKey] = items
+		case *OrderedMap:
+			object.Set(ArrayPartKey, items)
+		}
+		return result, nil
+	case MixedFillHoles:
+		if shape.otherCount == 0 && shape.fitsSlice() {
+			return c.indexedToSlice(table, shape.maxIndex, path)
+		}
 	}
+	return c.tableToObject(table, path)
+}
 
-	return result
+// isNonIndex reports whether a key is not a positive integer
+func isNonIndex(key lua.LValue) bool {
+	_, ok := arrayIndex(key)
+	return !ok
 }
 
-func goMapToLuaTable(L *lua.LState, m map[string]any) *lua.LTable {
-	table := L.NewTable()
-	for k, v := range m {
-		table.RawSetString(k, GoToLua(L, v))
+// indexedToSlice converts the keys 1..length to a slice, with nil for missing keys
+func (c *luaConverter) indexedToSlice(table *lua.LTable, length int, path string) ([]any, error) {
+	result := make([]any, 0, length)
+	for i := 1; i <= length; i++ {
+		value, keep, err := c.convert(table.RawGetInt(i), joinPath(path, lua.LNumber(i)))
+		if err != nil {
+			return nil, err
+		}
+		if !keep {
+			value = nil
+		}
+		result = append(result, value)
 	}
-	return table
+	return result, nil
 }
 
-func goSliceToLuaTable(L *lua.LState, s []any) *lua.LTable {
-	table := L.NewTable()
-	for i, v := range s {
-		table.RawSetInt(i+1, GoToLua(L, v))
+// tableToSlice converts a Lua array table to a Go slice, leaving out dropped elements
+func (c *luaConverter) tableToSlice(table *lua.LTable, path string) ([]any, error) {
+	length := table.Len()
+	result := make([]any, 0, length)
+
+	for i := 1; i <= length; i++ {
+		value, keep, err := c.convert(table.RawGetInt(i), joinPath(path, lua.LNumber(i)))
+		if err != nil {
+			return nil, err
+		}
+		if keep {
+			result = append(result, value)
+		}
 	}
-	return table
+
+	return result, nil
 }
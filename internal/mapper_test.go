@@ -1,6 +1,8 @@
 package internal
 
 import (
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/yuin/gopher-lua"
@@ -51,7 +53,10 @@ func TestGoToLua(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := GoToLua(L, tt.input)
+			result, err := GoToLua(L, tt.input)
+			if err != nil {
+				t.Fatalf("GoToLua(%v) failed: %v", tt.input, err)
+			}
 			if result.Type() != tt.expected {
 				t.Errorf("GoToLua(%v) type = %v, want %v", tt.input, result.Type(), tt.expected)
 			}
@@ -68,7 +73,10 @@ func TestLuaTableToGoMap(t *testing.T) {
 	table.RawSetString("key2", lua.LNumber(42))
 	table.RawSetString("key3", lua.LBool(true))
 
-	result := luaTableToGoMap(table, false)
+	result, err := ConvertTable(table, Options{})
+	if err != nil {
+		t.Fatalf("ConvertTable failed: %v", err)
+	}
 
 	if result["key1"] != "value1" {
 		t.Errorf("Expected key1='value1', got %v", result["key1"])
@@ -81,7 +89,7 @@ func TestLuaTableToGoMap(t *testing.T) {
 	}
 }
 
-func TestGoMapToLuaTable(t *testing.T) {
+func TestGoToLuaMap(t *testing.T) {
 	L := lua.NewState()
 	defer L.Close()
 
@@ -91,7 +99,14 @@ func TestGoMapToLuaTable(t *testing.T) {
 		"key3": true,
 	}
 
-	result := goMapToLuaTable(L, input)
+	value, err := GoToLua(L, input)
+	if err != nil {
+		t.Fatalf("GoToLua failed: %v", err)
+	}
+	result, ok := value.(*lua.LTable)
+	if !ok {
+		t.Fatalf("Expected table, got %T", value)
+	}
 
 	if result.RawGetString("key1").String() != "value1" {
 		t.Errorf("Expected key1='value1', got %v", result.RawGetString("key1"))
@@ -103,3 +118,240 @@ func TestGoMapToLuaTable(t *testing.T) {
 		t.Errorf("Expected key3=true, got %v", result.RawGetString("key3"))
 	}
 }
+
+func TestConvertFunctionPolicy(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	if err := L.DoString(`
+		config = {
+			name = "app",
+			helper = function() end,
+			handlers = { function() end, "static" },
+			co = coroutine.create(function() end),
+		}
+	`); err != nil {
+		t.Fatal(err)
+	}
+	table := L.GetGlobal("config").(*lua.LTable)
+
+	dropped, err := ConvertTable(table, Options{ConvertArrays: true, Functions: FunctionsDrop})
+	if err != nil {
+		t.Fatalf("ConvertTable failed: %v", err)
+	}
+	if _, ok := dropped["helper"]; ok {
+		t.Error("Expected function to be dropped")
+	}
+	if _, ok := dropped["co"]; ok {
+		t.Error("Expected thread to be dropped")
+	}
+	if handlers, ok := dropped["handlers"].([]any); !ok || len(handlers) != 1 || handlers[0] != "static" {
+		t.Errorf("Expected functions to be dropped from arrays, got %v", dropped["handlers"])
+	}
+
+	kept, err := ConvertTable(table, Options{Functions: FunctionsKeep})
+	if err != nil {
+		t.Fatalf("ConvertTable failed: %v", err)
+	}
+	if helper, ok := kept["helper"].(string); !ok || !strings.HasPrefix(helper, "function:") {
+		t.Errorf("Expected function string form, got %v", kept["helper"])
+	}
+
+	_, err = ConvertTable(table, Options{Functions: FunctionsError})
+	if err == nil {
+		t.Fatal("Expected error for function value")
+	}
+}
+
+func TestConvertErrorPath(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	if err := L.DoString(`config = { server = { hooks = { start = function() end } } }`); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := ConvertTable(L.GetGlobal("config").(*lua.LTable), Options{Functions: FunctionsError})
+	if err == nil || !strings.Contains(err.Error(), "server.hooks.start") {
+		t.Errorf("Expected error naming the key path, got %v", err)
+	}
+}
+
+func TestConvertPrivateKeys(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	if err := L.DoString(`config = { name = "app", _cache = {}, nested = { _tmp = 1, keep = 2 } }`); err != nil {
+		t.Fatal(err)
+	}
+	table := L.GetGlobal("config").(*lua.LTable)
+
+	result, err := ConvertTable(table, Options{PrivatePrefix: "_"})
+	if err != nil {
+		t.Fatalf("ConvertTable failed: %v", err)
+	}
+	if _, ok := result["_cache"]; ok {
+		t.Error("Expected private key to be omitted")
+	}
+	if nested := result["nested"].(map[string]any); len(nested) != 2 || nested["_tmp"] != float64(1) {
+		t.Errorf("Expected nested private key to be kept, got %v", nested)
+	}
+
+	result, err = ConvertTable(table, Options{})
+	if err != nil {
+		t.Fatalf("ConvertTable failed: %v", err)
+	}
+	if _, ok := result["_cache"]; !ok {
+		t.Error("Expected private key to be kept without a prefix")
+	}
+}
+
+func TestConvertCyclicTable(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	if err := L.DoString(`shared = { x = 1 }
+config = { a = shared, b = { shared } }
+config.b.self = config.b`); err != nil {
+		t.Fatal(err)
+	}
+	table := L.GetGlobal("config").(*lua.LTable)
+
+	_, err := ConvertTable(table, Options{})
+	if err == nil || !strings.Contains(err.Error(), "cyclic table at b.self") {
+		t.Errorf("Expected cyclic table error, got %v", err)
+	}
+
+	if err := L.DoString(`config.b.self = nil`); err != nil {
+		t.Fatal(err)
+	}
+	result, err := ConvertTable(table, Options{})
+	if err != nil {
+		t.Fatalf("Expected a table shared by siblings to convert, got %v", err)
+	}
+	if result["a"].(map[string]any)["x"] != float64(1) {
+		t.Errorf("Expected shared table, got %v", result)
+	}
+}
+
+func TestConvertMixedTables(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	if err := L.DoString(`config = { sparse = { 1, 2, nil, 4 }, mixed = { "a", "b", extra = true } }`); err != nil {
+		t.Fatal(err)
+	}
+	table := L.GetGlobal("config").(*lua.LTable)
+
+	tests := []struct {
+		name   string
+		policy MixedTablePolicy
+		sparse any
+		mixed  any
+	}{
+		{"map", MixedAsMap,
+			map[string]any{"1": float64(1), "2": float64(2), "4": float64(4)},
+			map[string]any{"1": "a", "2": "b", "extra": true}},
+		{"split", MixedSplit,
+			map[string]any{ArrayPartKey: []any{float64(1), float64(2), nil, float64(4)}},
+			map[string]any{ArrayPartKey: []any{"a", "b"}, "extra": true}},
+		{"fill holes", MixedFillHoles,
+			[]any{float64(1), float64(2), nil, float64(4)},
+			map[string]any{"1": "a", "2": "b", "extra": true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ConvertTable(table, Options{ConvertArrays: true, MixedTables: tt.policy})
+			if err != nil {
+				t.Fatalf("ConvertTable failed: %v", err)
+			}
+			if !reflect.DeepEqual(result["sparse"], tt.sparse) {
+				t.Errorf("Expected sparse %#v, got %#v", tt.sparse, result["sparse"])
+			}
+			if !reflect.DeepEqual(result["mixed"], tt.mixed) {
+				t.Errorf("Expected mixed %#v, got %#v", tt.mixed, result["mixed"])
+			}
+		})
+	}
+
+	_, err := ConvertTable(table, Options{ConvertArrays: true, MixedTables: MixedError})
+	if err == nil {
+		t.Fatal("Expected error for mixed table")
+	}
+	if !strings.Contains(err.Error(), "sparse") && !strings.Contains(err.Error(), "mixed") {
+		t.Errorf("Expected error naming the key path, got %v", err)
+	}
+
+	result, err := ConvertTable(table, Options{MixedTables: MixedError})
+	if err != nil {
+		t.Fatalf("Expected policy to be ignored without ConvertArrays, got %v", err)
+	}
+	if _, ok := result["sparse"].(map[string]any); !ok {
+		t.Errorf("Expected map without ConvertArrays, got %#v", result["sparse"])
+	}
+}
+
+func TestConvertHugeSparseIndex(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	if err := L.DoString(`config = { huge = { [1] = 1, [1e9] = 2 }, mixed = { "a", [1e15] = "b", extra = true }, inf = { [1] = 1, [math.huge] = 2 } }`); err != nil {
+		t.Fatal(err)
+	}
+	table := L.GetGlobal("config").(*lua.LTable)
+
+	for _, policy := range []MixedTablePolicy{MixedSplit, MixedFillHoles} {
+		result, err := ConvertTable(table, Options{ConvertArrays: true, MixedTables: policy})
+		if err != nil {
+			t.Fatalf("ConvertTable failed: %v", err)
+		}
+		expected := map[string]any{"1": float64(1), "1000000000": float64(2)}
+		if !reflect.DeepEqual(result["huge"], expected) {
+			t.Errorf("Expected too sparse array to become a map, got %#v", result["huge"])
+		}
+		if _, ok := result["mixed"].(map[string]any); !ok {
+			t.Errorf("Expected too sparse mixed table to become a map, got %#v", result["mixed"])
+		}
+		if _, ok := result["inf"].(map[string]any); !ok {
+			t.Errorf("Expected math.huge key to become a map key, got %#v", result["inf"])
+		}
+	}
+}
+
+func TestConvertKeyTypes(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	if err := L.DoString(`config = { weights = { [1.5] = "x", [true] = "y", name = "z" }, clash = { [1] = "a", ["1"] = "b" } }`); err != nil {
+		t.Fatal(err)
+	}
+	config := L.GetGlobal("config").(*lua.LTable)
+
+	weights, err := Convert(config.RawGetString("weights"), Options{})
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+	expected := map[string]any{"1.5": "x", "true": "y", "name": "z"}
+	if !reflect.DeepEqual(weights, expected) {
+		t.Errorf("Expected %v, got %v", expected, weights)
+	}
+
+	_, err = ConvertTable(config, Options{})
+	if err == nil || !strings.Contains(err.Error(), "clash") {
+		t.Errorf("Expected collision error naming the key path, got %v", err)
+	}
+
+	result, err := ConvertTable(config, Options{TypedKeys: true})
+	if err != nil {
+		t.Fatalf("ConvertTable failed: %v", err)
+	}
+	expectedTyped := map[any]any{1.5: "x", true: "y", "name": "z"}
+	if !reflect.DeepEqual(result["weights"], expectedTyped) {
+		t.Errorf("Expected %v, got %v", expectedTyped, result["weights"])
+	}
+	expectedClash := map[any]any{float64(1): "a", "1": "b"}
+	if !reflect.DeepEqual(result["clash"], expectedClash) {
+		t.Errorf("Expected %v, got %v", expectedClash, result["clash"])
+	}
+}
@@ -0,0 +1,103 @@
+package internal
+
+import (
+	"fmt"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// DefaultMetatableDepth limits how many metatables are followed on one key path
+const DefaultMetatableDepth = 32
+
+// MaxPairsEntries limits how many entries a __pairs iterator may produce for one table
+const MaxPairsEntries = 1 << 20
+
+// resolveMetatable returns a plain table holding the raw entries of table together with the
+// entries listed by its __pairs metamethod or inherited through its chain of __index tables.
+// The table is returned unchanged, and ok is false, when there is nothing to resolve.
+func (c *luaConverter) resolveMetatable(table *lua.LTable, path string) (*lua.LTable, bool, error) {
+	if !c.opts.ResolveMetatables || !resolvable(table) {
+		return table, false, nil
+	}
+
+	limit := c.opts.MetatableDepth
+	if limit <= 0 {
+		limit = DefaultMetatableDepth
+	}
+
+	resolved := c.L.NewTable()
+	merge := func(key, value lua.LValue) {
+		if resolved.RawGet(key) == lua.LNil {
+			resolved.RawSet(key, value)
+		}
+	}
+
+	for current, hops := table, 0; current != nil; hops++ {
+		if c.depth+hops > limit {
+			return nil, false, fmt.Errorf("metatables at %s nest deeper than %d levels", displayPath(path), limit)
+		}
+
+		meta, _ := current.Metatable.(*lua.LTable)
+		if meta != nil {
+			if pairs, ok := meta.RawGetString("__pairs").(*lua.LFunction); ok {
+				if err := c.callPairs(pairs, current, merge); err != nil {
+					return nil, false, fmt.Errorf("__pairs at %s failed: %w", displayPath(path), err)
+				}
+				break
+			}
+		}
+
+		ForEachOrdered(current, merge)
+		if meta == nil {
+			break
+		}
+		current, _ = meta.RawGetString("__index").(*lua.LTable)
+	}
+
+	return resolved, true, nil
+}
+
+// resolvable reports whether a table has an __index table or a __pairs function to resolve
+func resolvable(table *lua.LTable) bool {
+	meta, ok := table.Metatable.(*lua.LTable)
+	if !ok {
+		return false
+	}
+	if _, ok := meta.RawGetString("__index").(*lua.LTable); ok {
+		return true
+	}
+	_, ok = meta.RawGetString("__pairs").(*lua.LFunction)
+	return ok
+}
+
+// callPairs iterates table with its __pairs metamethod and passes every entry to yield. Iterators
+// repeating a key or producing more than MaxPairsEntries entries fail instead of looping forever.
+func (c *luaConverter) callPairs(pairs *lua.LFunction, table *lua.LTable, yield func(key, value lua.LValue)) error {
+	L := c.L
+	if err := L.CallByParam(lua.P{Fn: pairs, NRet: 3, Protect: true}, table); err != nil {
+		return err
+	}
+	next, state, control := L.Get(-3), L.Get(-2), L.Get(-1)
+	L.Pop(3)
+
+	seen := L.NewTable()
+	for count := 0; ; count++ {
+		if count >= MaxPairsEntries {
+			return fmt.Errorf("iterator produced more than %d entries", MaxPairsEntries)
+		}
+		if err := L.CallByParam(lua.P{Fn: next, NRet: 2, Protect: true}, state, control); err != nil {
+			return err
+		}
+		key, value := L.Get(-2), L.Get(-1)
+		L.Pop(2)
+		if key == lua.LNil {
+			return nil
+		}
+		if seen.RawGet(key) != lua.LNil {
+			return fmt.Errorf("iterator returned key %s twice", describeKey(key))
+		}
+		seen.RawSet(key, lua.LTrue)
+		yield(key, value)
+		control = key
+	}
+}
@@ -0,0 +1,96 @@
+package internal
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/yuin/gopher-lua"
+)
+
+func TestResolveMetatables(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	if err := L.DoString(`
+		local base = { retries = 3, timeout = 10 }
+		local defaults = setmetatable({ timeout = 30, host = "localhost" }, { __index = base })
+		local backing = { name = "proxy" }
+		config = {
+			server = setmetatable({ host = "example.com" }, { __index = defaults }),
+			proxy = setmetatable({}, { __pairs = function() return next, backing, nil end }),
+		}
+	`); err != nil {
+		t.Fatal(err)
+	}
+	table := L.GetGlobal("config").(*lua.LTable)
+
+	raw, err := ConvertTable(table, Options{})
+	if err != nil {
+		t.Fatalf("ConvertTable failed: %v", err)
+	}
+	if server := raw["server"].(map[string]any); len(server) != 1 {
+		t.Errorf("Expected only raw entries by default, got %v", server)
+	}
+
+	result, err := ConvertTableWithState(L, table, Options{ResolveMetatables: true})
+	if err != nil {
+		t.Fatalf("ConvertTableWithState failed: %v", err)
+	}
+	expected := map[string]any{
+		"server": map[string]any{"host": "example.com", "timeout": float64(30), "retries": float64(3)},
+		"proxy":  map[string]any{"name": "proxy"},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Expected %v, got %v", expected, result)
+	}
+}
+
+func TestResolveMetatablesDepth(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	if err := L.DoString(`
+		local loop = {}
+		setmetatable(loop, { __index = loop })
+		config = { loop = loop }
+	`); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := ConvertTableWithState(L, L.GetGlobal("config").(*lua.LTable), Options{ResolveMetatables: true, MetatableDepth: 4})
+	if err == nil || !strings.Contains(err.Error(), "loop") {
+		t.Errorf("Expected depth error naming the key path, got %v", err)
+	}
+}
+
+func TestResolveMetatablesEndlessPairs(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	if err := L.DoString(`
+		config = {
+			repeating = setmetatable({}, { __pairs = function() return function() return "key", 1 end, nil, nil end }),
+			endless = setmetatable({}, { __pairs = function()
+				return function(_, i) return (i or 0) + 1, true end, nil, nil
+			end }),
+		}
+	`); err != nil {
+		t.Fatal(err)
+	}
+	config := L.GetGlobal("config").(*lua.LTable)
+
+	tests := []struct {
+		key      string
+		expected string
+	}{
+		{"repeating", `returned key "key" twice`},
+		{"endless", "more than"},
+	}
+	for _, tt := range tests {
+		_, err := Convert(config.RawGetString(tt.key), Options{ResolveMetatables: true})
+		if err == nil || !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("Expected %s error for %s, got %v", tt.expected, tt.key, err)
+		}
+	}
+}
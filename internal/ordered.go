@@ -0,0 +1,100 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// OrderedMap is a map with string keys that remembers the order in which keys were first set
+type OrderedMap struct {
+	keys   []string
+	values map[string]any
+}
+
+// NewOrderedMap returns an empty OrderedMap
+func NewOrderedMap() *OrderedMap {
+	return &OrderedMap{values: make(map[string]any)}
+}
+
+// Set stores value under key, appending the key when it is new
+func (m *OrderedMap) Set(key string, value any) {
+	if _, exists := m.values[key]; !exists {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = value
+}
+
+// Get returns the value stored under key
+func (m *OrderedMap) Get(key string) (any, bool) {
+	value, ok := m.values[key]
+	return value, ok
+}
+
+// Keys returns the keys in the order they were first set
+func (m *OrderedMap) Keys() []string {
+	return append([]string(nil), m.keys...)
+}
+
+// Len returns the number of keys
+func (m *OrderedMap) Len() int {
+	return len(m.keys)
+}
+
+// ToMap returns the contents as plain maps, converting nested ordered maps as well
+func (m *OrderedMap) ToMap() map[string]any {
+	result := make(map[string]any, len(m.keys))
+	for _, key := range m.keys {
+		result[key] = plainValue(m.values[key])
+	}
+	return result
+}
+
+// MarshalJSON encodes the map as a JSON object with the keys in order
+func (m *OrderedMap) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range m.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		encodedKey, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		encodedValue, err := json.Marshal(m.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(encodedKey)
+		buf.WriteByte(':')
+		buf.Write(encodedValue)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// plainValue replaces ordered maps nested in value with plain maps
+func plainValue(value any) any {
+	switch v := value.(type) {
+	case *OrderedMap:
+		return v.ToMap()
+	case []any:
+		result := make([]any, len(v))
+		for i, item := range v {
+			result[i] = plainValue(item)
+		}
+		return result
+	default:
+		return value
+	}
+}
+
+// ForEachOrdered calls fn for every entry of a table, visiting the array part first and then
+// the remaining keys in the order they were first assigned, which follows the table constructor
+func ForEachOrdered(table *lua.LTable, fn func(key, value lua.LValue)) {
+	for key, value := table.Next(lua.LNil); key != lua.LNil; key, value = table.Next(key) {
+		fn(key, value)
+	}
+}
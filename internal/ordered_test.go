@@ -0,0 +1,60 @@
+package internal
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/yuin/gopher-lua"
+)
+
+func TestConvertOrderedTable(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	if err := L.DoString(`config = { zeta = 1, alpha = { "x", "y" }, mid = { b = 2, a = 1 } }`); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := ConvertOrderedTable(L, L.GetGlobal("config").(*lua.LTable), Options{ConvertArrays: true})
+	if err != nil {
+		t.Fatalf("ConvertOrderedTable failed: %v", err)
+	}
+	if keys := result.Keys(); !reflect.DeepEqual(keys, []string{"zeta", "alpha", "mid"}) {
+		t.Errorf("Expected declaration order, got %v", keys)
+	}
+
+	encoded, err := json.Marshal(result)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if expected := `{"zeta":1,"alpha":["x","y"],"mid":{"b":2,"a":1}}`; string(encoded) != expected {
+		t.Errorf("Expected %s, got %s", expected, encoded)
+	}
+
+	expected := map[string]any{
+		"zeta":  float64(1),
+		"alpha": []any{"x", "y"},
+		"mid":   map[string]any{"b": float64(2), "a": float64(1)},
+	}
+	if !reflect.DeepEqual(result.ToMap(), expected) {
+		t.Errorf("Expected %v, got %v", expected, result.ToMap())
+	}
+}
+
+func TestOrderedMapSet(t *testing.T) {
+	m := NewOrderedMap()
+	m.Set("b", 1)
+	m.Set("a", 2)
+	m.Set("b", 3)
+
+	if keys := m.Keys(); !reflect.DeepEqual(keys, []string{"b", "a"}) {
+		t.Errorf("Expected first assignment order, got %v", keys)
+	}
+	if value, ok := m.Get("b"); !ok || value != 3 {
+		t.Errorf("Expected updated value, got %v", value)
+	}
+	if m.Len() != 2 {
+		t.Errorf("Expected 2 keys, got %d", m.Len())
+	}
+}
@@ -0,0 +1,283 @@
+package internal
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+
+	lua "github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/ast"
+	"github.com/yuin/gopher-lua/parse"
+)
+
+// Parameters of the function an instrumented chunk returns, in order, followed by the chunk's varargs
+const (
+	// AssignHook is called as hook(object, key, line, value...) in place of object[key] = value
+	AssignHook = "__culebra_assign"
+	// GlobalHook is called as hook(name, line, value...) in place of an assignment to a global,
+	// which it makes in the environment of the calling function
+	GlobalHook = "__culebra_global"
+	// TableHook is called as hook(table, line, key, line, ...) around a table constructor and
+	// returns the table; the pairs list the line of each literal key
+	TableHook = "__culebra_table"
+)
+
+// Instrument compiles a Lua chunk rewritten to report where keys are assigned. The compiled
+// chunk returns a function taking the AssignHook, GlobalHook and TableHook functions followed by
+// the original varargs, whose body is the original chunk with every table constructor and every
+// assignment to a global or a table field routed through the hooks.
+func Instrument(source io.Reader, name string) (*lua.FunctionProto, error) {
+	chunk, err := parse.Parse(source, name)
+	if err != nil {
+		return nil, err
+	}
+
+	rewriter := &instrumenter{}
+	rewriter.block(chunk, nil)
+
+	body := &ast.FunctionExpr{
+		ParList: &ast.ParList{HasVargs: true, Names: []string{AssignHook, GlobalHook, TableHook}},
+		Stmts:   chunk,
+	}
+	wrapper := &ast.ReturnStmt{Exprs: []ast.Expr{body}}
+	if len(chunk) > 0 {
+		body.SetLine(chunk[0].Line())
+		body.SetLastLine(chunk[len(chunk)-1].LastLine())
+		wrapper.SetLine(chunk[0].Line())
+	}
+
+	return lua.Compile([]ast.Stmt{wrapper}, name)
+}
+
+// instrumenter rewrites statements in place while tracking which names are locals
+type instrumenter struct {
+	scopes []map[string]bool
+}
+
+// isLocal reports whether name refers to a local in the current scope
+func (r *instrumenter) isLocal(name string) bool {
+	for i := len(r.scopes) - 1; i >= 0; i-- {
+		if r.scopes[i][name] {
+			return true
+		}
+	}
+	return false
+}
+
+// declare adds locals to the current scope
+func (r *instrumenter) declare(names ...string) {
+	for _, name := range names {
+		r.scopes[len(r.scopes)-1][name] = true
+	}
+}
+
+// block rewrites the statements of a new scope holding the given locals
+func (r *instrumenter) block(stmts []ast.Stmt, locals []string) {
+	r.scopes = append(r.scopes, make(map[string]bool))
+	defer func() { r.scopes = r.scopes[:len(r.scopes)-1] }()
+	r.declare(locals...)
+
+	for i, stmt := range stmts {
+		stmts[i] = r.stmt(stmt)
+	}
+}
+
+// stmt rewrites a statement, returning its replacement
+func (r *instrumenter) stmt(stmt ast.Stmt) ast.Stmt {
+	switch s := stmt.(type) {
+	case *ast.AssignStmt:
+		r.exprs(s.Lhs)
+		r.exprs(s.Rhs)
+		if len(s.Lhs) > 1 {
+			return r.multipleAssignment(s)
+		}
+		if len(s.Rhs) > 0 {
+			if call := r.assignment(s.Lhs[0], s.Rhs, s.Line()); call != nil {
+				replacement := &ast.FuncCallStmt{Expr: call}
+				replacement.SetLine(s.Line())
+				replacement.SetLastLine(s.LastLine())
+				return replacement
+			}
+		}
+	case *ast.LocalAssignStmt:
+		// Like the compiler, treat local f = function as local function f, whose body sees f
+		if len(s.Names) == 1 && len(s.Exprs) == 1 {
+			if _, ok := s.Exprs[0].(*ast.FunctionExpr); ok {
+				r.declare(s.Names...)
+				r.exprs(s.Exprs)
+				break
+			}
+		}
+		r.exprs(s.Exprs)
+		r.declare(s.Names...)
+	case *ast.FuncCallStmt:
+		s.Expr = r.expr(s.Expr)
+	case *ast.DoBlockStmt:
+		r.block(s.Stmts, nil)
+	case *ast.WhileStmt:
+		s.Condition = r.expr(s.Condition)
+		r.block(s.Stmts, nil)
+	case *ast.RepeatStmt:
+		r.block(s.Stmts, nil)
+		s.Condition = r.expr(s.Condition)
+	case *ast.IfStmt:
+		s.Condition = r.expr(s.Condition)
+		r.block(s.Then, nil)
+		r.block(s.Else, nil)
+	case *ast.NumberForStmt:
+		s.Init = r.expr(s.Init)
+		s.Limit = r.expr(s.Limit)
+		if s.Step != nil {
+			s.Step = r.expr(s.Step)
+		}
+		r.block(s.Stmts, []string{s.Name})
+	case *ast.GenericForStmt:
+		r.exprs(s.Exprs)
+		r.block(s.Stmts, s.Names)
+	case *ast.FuncDefStmt:
+		locals := s.Func.ParList.Names
+		if s.Name.Method != "" {
+			locals = append([]string{"self"}, locals...)
+		}
+		r.block(s.Func.Stmts, locals)
+	case *ast.ReturnStmt:
+		r.exprs(s.Exprs)
+	}
+	return stmt
+}
+
+// assignment returns the hook call replacing target = values, or nil for targets left as-is
+func (r *instrumenter) assignment(target ast.Expr, values []ast.Expr, line int) ast.Expr {
+	lineExpr := &ast.NumberExpr{Value: strconv.Itoa(line)}
+	switch t := target.(type) {
+	case *ast.IdentExpr:
+		if r.isLocal(t.Value) {
+			return nil
+		}
+		return hookCall(GlobalHook, line, append([]ast.Expr{&ast.StringExpr{Value: t.Value}, lineExpr}, values...))
+	case *ast.AttrGetExpr:
+		return hookCall(AssignHook, line, append([]ast.Expr{t.Object, t.Key, lineExpr}, values...))
+	}
+	return nil
+}
+
+// multipleAssignment rewrites a, b = x, y into a block that evaluates the tables and keys of the
+// targets and then the values into hidden locals, left to right, before assigning each target
+// through assignment
+func (r *instrumenter) multipleAssignment(s *ast.AssignStmt) ast.Stmt {
+	line := s.Line()
+	hidden := func(kind string, i int) *ast.IdentExpr {
+		ident := &ast.IdentExpr{Value: fmt.Sprintf("(%s %d)", kind, i)}
+		ident.SetLine(line)
+		return ident
+	}
+
+	evaluated := &ast.LocalAssignStmt{}
+	targets := make([]ast.Expr, len(s.Lhs))
+	for i, target := range s.Lhs {
+		targets[i] = target
+		if t, ok := target.(*ast.AttrGetExpr); ok {
+			object, key := hidden("object", i), hidden("key", i)
+			evaluated.Names = append(evaluated.Names, object.Value, key.Value)
+			evaluated.Exprs = append(evaluated.Exprs, t.Object, t.Key)
+			targets[i] = &ast.AttrGetExpr{Object: object, Key: key}
+			targets[i].SetLine(t.Line())
+		}
+	}
+	for i := range s.Lhs {
+		evaluated.Names = append(evaluated.Names, hidden("value", i).Value)
+	}
+	evaluated.Exprs = append(evaluated.Exprs, s.Rhs...)
+	evaluated.SetLine(line)
+	evaluated.SetLastLine(s.LastLine())
+
+	block := &ast.DoBlockStmt{Stmts: []ast.Stmt{evaluated}}
+	for i, target := range targets {
+		value := []ast.Expr{hidden("value", i)}
+		var stmt ast.Stmt
+		if call := r.assignment(target, value, line); call != nil {
+			stmt = &ast.FuncCallStmt{Expr: call}
+		} else {
+			stmt = &ast.AssignStmt{Lhs: []ast.Expr{target}, Rhs: value}
+		}
+		stmt.SetLine(line)
+		stmt.SetLastLine(s.LastLine())
+		block.Stmts = append(block.Stmts, stmt)
+	}
+	block.SetLine(line)
+	block.SetLastLine(s.LastLine())
+	return block
+}
+
+// exprs rewrites a list of expressions in place
+func (r *instrumenter) exprs(exprs []ast.Expr) {
+	for i, expr := range exprs {
+		exprs[i] = r.expr(expr)
+	}
+}
+
+// expr rewrites an expression, returning its replacement
+func (r *instrumenter) expr(expr ast.Expr) ast.Expr {
+	switch e := expr.(type) {
+	case *ast.AttrGetExpr:
+		e.Object = r.expr(e.Object)
+		e.Key = r.expr(e.Key)
+	case *ast.TableExpr:
+		args := []ast.Expr{e, &ast.NumberExpr{Value: strconv.Itoa(e.Line())}}
+		position := 0
+		for _, field := range e.Fields {
+			if field.Key == nil {
+				position++
+				args = append(args, &ast.NumberExpr{Value: strconv.Itoa(position)}, &ast.NumberExpr{Value: strconv.Itoa(field.Value.Line())})
+			} else {
+				field.Key = r.expr(field.Key)
+				switch key := field.Key.(type) {
+				case *ast.StringExpr, *ast.NumberExpr:
+					args = append(args, key, &ast.NumberExpr{Value: strconv.Itoa(field.Value.Line())})
+				}
+			}
+			field.Value = r.expr(field.Value)
+		}
+		return hookCall(TableHook, e.Line(), args)
+	case *ast.FuncCallExpr:
+		if e.Func != nil {
+			e.Func = r.expr(e.Func)
+		}
+		if e.Receiver != nil {
+			e.Receiver = r.expr(e.Receiver)
+		}
+		r.exprs(e.Args)
+	case *ast.LogicalOpExpr:
+		e.Lhs, e.Rhs = r.expr(e.Lhs), r.expr(e.Rhs)
+	case *ast.RelationalOpExpr:
+		e.Lhs, e.Rhs = r.expr(e.Lhs), r.expr(e.Rhs)
+	case *ast.StringConcatOpExpr:
+		e.Lhs, e.Rhs = r.expr(e.Lhs), r.expr(e.Rhs)
+	case *ast.ArithmeticOpExpr:
+		e.Lhs, e.Rhs = r.expr(e.Lhs), r.expr(e.Rhs)
+	case *ast.UnaryMinusOpExpr:
+		e.Expr = r.expr(e.Expr)
+	case *ast.UnaryNotOpExpr:
+		e.Expr = r.expr(e.Expr)
+	case *ast.UnaryLenOpExpr:
+		e.Expr = r.expr(e.Expr)
+	case *ast.FunctionExpr:
+		r.block(e.Stmts, e.ParList.Names)
+	}
+	return expr
+}
+
+// hookCall builds a call to one of the hook parameters
+func hookCall(hook string, line int, args []ast.Expr) *ast.FuncCallExpr {
+	fn := &ast.IdentExpr{Value: hook}
+	fn.SetLine(line)
+	call := &ast.FuncCallExpr{Func: fn, Args: args}
+	call.SetLine(line)
+	call.SetLastLine(line)
+	for _, arg := range args {
+		if arg.Line() == 0 {
+			arg.SetLine(line)
+		}
+	}
+	return call
+}
@@ -0,0 +1,136 @@
+package internal
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/yuin/gopher-lua"
+)
+
+func TestInstrument(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	source := `local name = ...
+local t = { a = 1,
+    "x" }
+t.b = 2
+g = name
+local l
+l = 3
+return t
+`
+	calls := runInstrumented(t, L, source, lua.LString("app"))
+
+	expected := []string{
+		"table @2 a@2 1@3",
+		"assign b=2 @4",
+		"global g=app @5",
+	}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Errorf("Expected hook calls %v, got %v", expected, calls)
+	}
+
+	result := L.Get(-1).(*lua.LTable)
+	if result.RawGetString("b") != lua.LNumber(2) || result.RawGetInt(1) != lua.LString("x") {
+		t.Errorf("Expected the chunk to run unchanged, got %v", result)
+	}
+	if L.GetGlobal("l") != lua.LNil {
+		t.Error("Expected local assignment to stay local")
+	}
+}
+
+// runInstrumented runs an instrumented chunk with hooks that record their calls and leaves the
+// chunk's result on the stack
+func runInstrumented(t *testing.T, L *lua.LState, source string, args ...lua.LValue) []string {
+	t.Helper()
+	proto, err := Instrument(strings.NewReader(source), "test.lua")
+	if err != nil {
+		t.Fatalf("Instrument failed: %v", err)
+	}
+
+	L.Push(L.NewFunctionFromProto(proto))
+	if err := L.PCall(0, 1, nil); err != nil {
+		t.Fatalf("Loading instrumented chunk failed: %v", err)
+	}
+	body := L.Get(-1)
+	L.Pop(1)
+
+	var calls []string
+	assign := L.NewFunction(func(L *lua.LState) int {
+		calls = append(calls, fmt.Sprintf("assign %s=%s @%d", L.Get(2), L.Get(4), L.CheckInt(3)))
+		L.SetTable(L.Get(1), L.Get(2), L.Get(4))
+		return 0
+	})
+	global := L.NewFunction(func(L *lua.LState) int {
+		calls = append(calls, fmt.Sprintf("global %s=%s @%d", L.Get(1), L.Get(3), L.CheckInt(2)))
+		L.SetGlobal(L.CheckString(1), L.Get(3))
+		return 0
+	})
+	table := L.NewFunction(func(L *lua.LState) int {
+		var keys []string
+		for i := 3; i < L.GetTop(); i += 2 {
+			keys = append(keys, fmt.Sprintf("%s@%d", L.Get(i), L.CheckInt(i+1)))
+		}
+		calls = append(calls, fmt.Sprintf("table @%d %s", L.CheckInt(2), strings.Join(keys, " ")))
+		L.Push(L.Get(1))
+		return 1
+	})
+
+	if err := L.CallByParam(lua.P{Fn: body, NRet: 1, Protect: true}, append([]lua.LValue{assign, global, table}, args...)...); err != nil {
+		t.Fatalf("Running instrumented chunk failed: %v", err)
+	}
+	return calls
+}
+
+func TestInstrumentLocalFunctionsAndMultipleAssignment(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	source := `local function count(n)
+  if n > 0 then count = nil end
+  return n
+end
+local t, k = {}, "x"
+t.a, t[k], g = 1, 2, count(3)
+local a, b = 1, 2
+a, b = b, a
+k, t[k] = "y", 4
+return { a = a, b = b, t = t, count = count }
+`
+	calls := runInstrumented(t, L, source)
+
+	expected := []string{
+		"table @5 ",
+		"assign a=1 @6",
+		"assign x=2 @6",
+		"global g=3 @6",
+		"assign x=4 @9",
+		"table @10 a@10 b@10 t@10 count@10",
+	}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Errorf("Expected hook calls %v, got %v", expected, calls)
+	}
+
+	result := L.Get(-1).(*lua.LTable)
+	if result.RawGetString("a") != lua.LNumber(2) || result.RawGetString("b") != lua.LNumber(1) {
+		t.Errorf("Expected swapped locals, got a=%v b=%v", result.RawGetString("a"), result.RawGetString("b"))
+	}
+	if result.RawGetString("count") != lua.LNil {
+		t.Error("Expected the local function to assign its own local")
+	}
+	if L.GetGlobal("count") != lua.LNil {
+		t.Error("Expected no global for the local function")
+	}
+	if L.GetGlobal("k") != lua.LNil {
+		t.Error("Expected multiple assignment to keep locals local")
+	}
+}
+
+func TestInstrumentSyntaxError(t *testing.T) {
+	if _, err := Instrument(strings.NewReader("config = {"), "test.lua"); err == nil {
+		t.Error("Expected error for invalid Lua")
+	}
+}
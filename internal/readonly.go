@@ -0,0 +1,241 @@
+package internal
+
+import (
+	"github.com/yuin/gopher-lua"
+)
+
+// readOnlyMarker is stored as the __metatable field of read-only proxies so they can be recognized
+const readOnlyMarker = lua.LString("culebra.readonly")
+
+// readOnlyPathField holds the dotted name of a read-only proxy in its metatable, for error messages
+const readOnlyPathField = "__culebra_path"
+
+// ReadOnly wraps a value so GoToLua exposes it to Lua as a read-only table
+type ReadOnly struct {
+	Value any
+}
+
+// Freeze returns a read-only view of a Lua value. Tables are replaced by empty proxies whose
+// reads, length and iteration resolve against the original table and whose writes raise an error
+// naming the key. The library functions patched by OpenReadOnly treat proxies the same way.
+// Path is the dotted name used in error messages.
+func Freeze(L *lua.LState, value lua.LValue, path string) lua.LValue {
+	table, ok := value.(*lua.LTable)
+	if !ok || IsReadOnly(table) {
+		return value
+	}
+
+	backing := L.NewTable()
+	table.ForEach(func(key, value lua.LValue) {
+		backing.RawSet(key, Freeze(L, value, joinPath(path, key)))
+	})
+
+	proxy := L.NewTable()
+	meta := L.NewTable()
+	meta.RawSetString("__index", backing)
+	meta.RawSetString("__newindex", L.NewFunction(func(L *lua.LState) int {
+		L.RaiseError("attempt to modify read-only key '%s'", joinPath(path, L.Get(2)))
+		return 0
+	}))
+	meta.RawSetString("__pairs", L.NewFunction(func(L *lua.LState) int {
+		L.Push(L.NewFunction(pairsNext))
+		L.Push(backing)
+		L.Push(lua.LNil)
+		return 3
+	}))
+	meta.RawSetString("__ipairs", L.NewFunction(func(L *lua.LState) int {
+		L.Push(L.NewFunction(ipairsNext))
+		L.Push(backing)
+		L.Push(lua.LNumber(0))
+		return 3
+	}))
+	meta.RawSetString("__len", L.NewFunction(func(L *lua.LState) int {
+		L.Push(lua.LNumber(backing.Len()))
+		return 1
+	}))
+	meta.RawSetString("__metatable", readOnlyMarker)
+	meta.RawSetString(readOnlyPathField, lua.LString(path))
+	proxy.Metatable = meta
+
+	return proxy
+}
+
+// Refreeze makes a read-only proxy resolve against the contents of another one, so code holding
+// the proxy sees the new values. It reports false when either value is not a read-only proxy.
+func Refreeze(proxy, replacement lua.LValue) bool {
+	if !IsReadOnly(proxy) || !IsReadOnly(replacement) {
+		return false
+	}
+	proxy.(*lua.LTable).Metatable = replacement.(*lua.LTable).Metatable
+	return true
+}
+
+// IsReadOnly reports whether a Lua value is a proxy created by Freeze
+func IsReadOnly(value lua.LValue) bool {
+	_, ok := readOnlyBacking(value)
+	return ok
+}
+
+// readOnlyBacking returns the table a read-only proxy resolves its reads against
+func readOnlyBacking(value lua.LValue) (*lua.LTable, bool) {
+	table, ok := value.(*lua.LTable)
+	if !ok {
+		return nil, false
+	}
+	meta, ok := table.Metatable.(*lua.LTable)
+	if !ok || meta.RawGetString("__metatable") != readOnlyMarker {
+		return nil, false
+	}
+	backing, ok := meta.RawGetString("__index").(*lua.LTable)
+	return backing, ok
+}
+
+// OpenReadOnly replaces pairs and ipairs with versions that honor the __pairs and __ipairs
+// metamethods, so read-only proxies can be iterated like the tables they wrap. The functions that
+// bypass metamethods are patched too: next, unpack, table.concat, table.getn and table.maxn read
+// the wrapped table, while table.insert, table.remove and table.sort raise an error naming it.
+func OpenReadOnly(L *lua.LState) {
+	for _, name := range []string{"pairs", "ipairs"} {
+		original := L.GetGlobal(name)
+		metamethod := "__" + name
+		L.SetGlobal(name, L.NewFunction(func(L *lua.LState) int {
+			value := L.Get(1)
+			handler := L.GetMetaField(value, metamethod)
+			if handler == lua.LNil {
+				handler = original
+			}
+			L.Push(handler)
+			L.Push(value)
+			L.Call(1, 3)
+			return 3
+		}))
+	}
+
+	tableLib, _ := L.GetGlobal(lua.TabLibName).(*lua.LTable)
+	readers := map[*lua.LTable][]string{
+		L.Get(lua.GlobalsIndex).(*lua.LTable): {"next", "unpack"},
+		tableLib:                              {"concat", "getn", "maxn"},
+	}
+	for lib, names := range readers {
+		for _, name := range names {
+			patchTableArgument(L, lib, name, func(L *lua.LState, backing *lua.LTable) {
+				L.Replace(1, backing)
+			})
+		}
+	}
+	for _, name := range []string{"insert", "remove", "sort"} {
+		patchTableArgument(L, tableLib, name, func(L *lua.LState, backing *lua.LTable) {
+			path := L.GetMetaField(L.Get(1), readOnlyPathField)
+			L.RaiseError("attempt to modify read-only table '%s'", path)
+		})
+	}
+}
+
+// patchTableArgument wraps the library function lib[name] so that onProxy runs when its first
+// argument is a read-only proxy, before the original function is called with the same arguments
+func patchTableArgument(L *lua.LState, lib *lua.LTable, name string, onProxy func(*lua.LState, *lua.LTable)) {
+	if lib == nil {
+		return
+	}
+	original, ok := lib.RawGetString(name).(*lua.LFunction)
+	if !ok {
+		return
+	}
+	lib.RawSetString(name, L.NewFunction(func(L *lua.LState) int {
+		if backing, ok := readOnlyBacking(L.Get(1)); ok {
+			onProxy(L, backing)
+		}
+		top := L.GetTop()
+		L.Push(original)
+		for i := 1; i <= top; i++ {
+			L.Push(L.Get(i))
+		}
+		L.Call(top, lua.MultRet)
+		return L.GetTop() - top
+	}))
+}
+
+func pairsNext(L *lua.LState) int {
+	table := L.CheckTable(1)
+	key, value := table.Next(L.Get(2))
+	if key == lua.LNil {
+		L.Push(lua.LNil)
+		return 1
+	}
+	L.Push(key)
+	L.Push(value)
+	return 2
+}
+
+func ipairsNext(L *lua.LState) int {
+	table := L.CheckTable(1)
+	index := L.CheckInt(2) + 1
+	value := table.RawGetInt(index)
+	if value == lua.LNil {
+		return 0
+	}
+	L.Push(lua.LNumber(index))
+	L.Push(value)
+	return 2
+}
+
+// joinPath appends a key to a dotted path
+func joinPath(path string, key lua.LValue) string {
+	if path == "" {
+		return key.String()
+	}
+	return path + "." + key.String()
+}
+
+// GlobalOptions configures the metatable ProtectGlobals installs on the global table
+type GlobalOptions struct {
+	Frozen map[string]lua.LValue // Read-only globals, served through the metatable
+	Strict bool                  // Raise an error when a global that was never assigned is read
+	Allow  []string              // Globals that strict mode lets the script read while unset
+}
+
+// ProtectGlobals makes the frozen globals read-only. They are served through a metatable on the
+// global table instead of being stored in it, so assigning them raises an error naming the key.
+// In strict mode reading a global that is neither set, assigned by the script nor allowed raises
+// an error with the position of the read.
+func ProtectGlobals(L *lua.LState, opts GlobalOptions) {
+	if len(opts.Frozen) == 0 && !opts.Strict {
+		return
+	}
+
+	globals := L.Get(lua.GlobalsIndex).(*lua.LTable)
+	for name := range opts.Frozen {
+		globals.RawSetString(name, lua.LNil)
+	}
+
+	declared := make(map[string]bool, len(opts.Allow))
+	for _, name := range opts.Allow {
+		declared[name] = true
+	}
+
+	meta := L.NewTable()
+	meta.RawSetString("__index", L.NewFunction(func(L *lua.LState) int {
+		if name, ok := L.Get(2).(lua.LString); ok {
+			if value, ok := opts.Frozen[string(name)]; ok {
+				L.Push(value)
+				return 1
+			}
+			if opts.Strict && !declared[string(name)] {
+				L.RaiseError("undefined global '%s'", name)
+			}
+		}
+		L.Push(lua.LNil)
+		return 1
+	}))
+	meta.RawSetString("__newindex", L.NewFunction(func(L *lua.LState) int {
+		if name, ok := L.Get(2).(lua.LString); ok {
+			if _, ok := opts.Frozen[string(name)]; ok {
+				L.RaiseError("attempt to modify read-only global '%s'", name)
+			}
+			declared[string(name)] = true
+		}
+		L.RawSet(L.CheckTable(1), L.Get(2), L.Get(3))
+		return 0
+	}))
+	globals.Metatable = meta
+}
@@ -0,0 +1,145 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/yuin/gopher-lua"
+)
+
+func TestFreeze(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+	OpenReadOnly(L)
+
+	value, err := GoToLua(L, map[string]any{
+		"region": "us-east-1",
+		"zones":  []any{"a", "b"},
+	})
+	if err != nil {
+		t.Fatalf("GoToLua failed: %v", err)
+	}
+	frozen := Freeze(L, value, "env")
+	if !IsReadOnly(frozen) {
+		t.Fatal("Expected frozen value to be read-only")
+	}
+	L.SetGlobal("env", frozen)
+
+	if err := L.DoString(`
+		assert(env.region == "us-east-1")
+		assert(env.zones[2] == "b")
+		local count = 0
+		for _ in pairs(env) do count = count + 1 end
+		assert(count == 2)
+		local zones = 0
+		for _ in ipairs(env.zones) do zones = zones + 1 end
+		assert(zones == 2)
+		assert(#env.zones == 2)
+		assert(env.zones[#env.zones] == "b")
+	`); err != nil {
+		t.Fatalf("Reading frozen table failed: %v", err)
+	}
+
+	err = L.DoString(`env.zones[1] = "c"`)
+	if err == nil {
+		t.Fatal("Expected error when modifying frozen table")
+	}
+	if !strings.Contains(err.Error(), "env.zones.1") {
+		t.Errorf("Expected error to name the key, got %v", err)
+	}
+}
+
+func TestFreezeLibraryFunctions(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+	OpenReadOnly(L)
+
+	value, err := GoToLua(L, map[string]any{"list": []any{"b", "c", "a"}})
+	if err != nil {
+		t.Fatalf("GoToLua failed: %v", err)
+	}
+	L.SetGlobal("env", Freeze(L, value, "env"))
+
+	if err := L.DoString(`
+		assert(table.concat(env.list, ",") == "b,c,a")
+		local first, second, third = unpack(env.list)
+		assert(first == "b" and second == "c" and third == "a")
+		local key, item = next(env.list)
+		assert(key ~= nil and item ~= nil)
+		assert(next(env) == "list")
+		assert(table.getn(env.list) == 3 and table.maxn(env.list) == 3)
+	`); err != nil {
+		t.Fatalf("Reading frozen table through library functions failed: %v", err)
+	}
+
+	for _, code := range []string{
+		`table.sort(env.list)`,
+		`table.insert(env.list, "d")`,
+		`table.remove(env.list)`,
+	} {
+		err := L.DoString(code)
+		if err == nil || !strings.Contains(err.Error(), "read-only table 'env.list'") {
+			t.Errorf("Expected %s to fail naming the table, got %v", code, err)
+		}
+	}
+
+	if err := L.DoString(`
+		local plain = { "b", "a" }
+		table.sort(plain)
+		table.insert(plain, "c")
+		assert(table.concat(plain) == "abc")
+	`); err != nil {
+		t.Errorf("Expected plain tables to be unaffected, got %v", err)
+	}
+}
+
+func TestFreezeScalar(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	if Freeze(L, lua.LString("value"), "key") != lua.LString("value") {
+		t.Error("Expected scalars to be returned unchanged")
+	}
+	if IsReadOnly(L.NewTable()) {
+		t.Error("Expected plain table not to be read-only")
+	}
+}
+
+func TestRefreeze(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	before, _ := GoToLua(L, map[string]any{"name": "before"})
+	after, _ := GoToLua(L, map[string]any{"name": "after"})
+	proxy := Freeze(L, before, "cli")
+	if !Refreeze(proxy, Freeze(L, after, "cli")) {
+		t.Fatal("Expected read-only proxies to be refrozen")
+	}
+	if name := L.GetField(proxy, "name"); name != lua.LString("after") {
+		t.Errorf("Expected proxy to resolve against the replacement, got %v", name)
+	}
+	if Refreeze(before, proxy) {
+		t.Error("Expected plain table not to be refrozen")
+	}
+}
+
+func TestProtectGlobalsStrict(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	ProtectGlobals(L, GlobalOptions{Strict: true, Allow: []string{"optional"}})
+
+	if err := L.DoString(`
+		assert(optional == nil)
+		declared = nil
+		assert(declared == nil)
+		assert(type(print) == "function")
+	`); err != nil {
+		t.Fatalf("Expected allowed and assigned globals to be readable, got %v", err)
+	}
+
+	err := L.DoString(`if ENVIROMENT == "prod" then end`)
+	if err == nil || !strings.Contains(err.Error(), "undefined global 'ENVIROMENT'") {
+		t.Errorf("Expected undefined global error, got %v", err)
+	}
+}
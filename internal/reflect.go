@@ -0,0 +1,277 @@
+package internal
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/yuin/gopher-lua"
+)
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+	luaValueType = reflect.TypeOf((*lua.LValue)(nil)).Elem()
+	readOnlyType = reflect.TypeOf(ReadOnly{})
+)
+
+// goConverter converts Go values into Lua values using reflection
+type goConverter struct {
+	L *lua.LState
+	// visiting holds the pointers, maps and slices on the current conversion path to detect cycles
+	visiting map[visit]bool
+}
+
+// visit identifies a pointer, map or slice by its data pointer and type
+type visit struct {
+	ptr uintptr
+	typ reflect.Type
+}
+
+func newGoConverter(L *lua.LState) *goConverter {
+	return &goConverter{L: L, visiting: make(map[visit]bool)}
+}
+
+// enter marks a pointer, map or slice as being converted and reports false when it already is,
+// meaning the value contains itself. The returned function unmarks it.
+func (c *goConverter) enter(rv reflect.Value) (func(), bool) {
+	v := visit{ptr: rv.Pointer(), typ: rv.Type()}
+	if c.visiting[v] {
+		return nil, false
+	}
+	c.visiting[v] = true
+	return func() { delete(c.visiting, v) }, true
+}
+
+func (c *goConverter) convert(rv reflect.Value, path string) (lua.LValue, error) {
+	if !rv.IsValid() {
+		return lua.LNil, nil
+	}
+
+	switch rv.Type() {
+	case timeType:
+		return lua.LString(rv.Interface().(time.Time).Format(time.RFC3339Nano)), nil
+	case durationType:
+		return lua.LString(time.Duration(rv.Int()).String()), nil
+	case userdataType:
+		exposed := rv.Interface().(Userdata)
+		return exposeUserdata(c.L, reflect.ValueOf(exposed.Value), exposed.Writable, path), nil
+	case readOnlyType:
+		value, err := c.convert(rv.Field(0), path)
+		if err != nil {
+			return nil, err
+		}
+		return Freeze(c.L, value, path), nil
+	}
+
+	if rv.Type().Implements(luaValueType) && rv.CanInterface() {
+		if rv.Kind() == reflect.Pointer && rv.IsNil() {
+			return lua.LNil, nil
+		}
+		return rv.Interface().(lua.LValue), nil
+	}
+
+	switch rv.Kind() {
+	case reflect.Bool:
+		return lua.LBool(rv.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return lua.LNumber(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return lua.LNumber(rv.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return lua.LNumber(rv.Float()), nil
+	case reflect.String:
+		return lua.LString(rv.String()), nil
+	case reflect.Interface:
+		if rv.IsNil() {
+			return lua.LNil, nil
+		}
+		return c.convert(rv.Elem(), path)
+	case reflect.Pointer:
+		if rv.IsNil() {
+			return lua.LNil, nil
+		}
+		leave, ok := c.enter(rv)
+		if !ok {
+			return nil, fmt.Errorf("cyclic value at %s", displayPath(path))
+		}
+		defer leave()
+		return c.convert(rv.Elem(), path)
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return lua.LString(rv.Bytes()), nil
+		}
+		if rv.Len() > 0 {
+			leave, ok := c.enter(rv)
+			if !ok {
+				return nil, fmt.Errorf("cyclic value at %s", displayPath(path))
+			}
+			defer leave()
+		}
+		return c.convertSequence(rv, path)
+	case reflect.Array:
+		return c.convertSequence(rv, path)
+	case reflect.Map:
+		if rv.IsNil() {
+			return c.L.NewTable(), nil
+		}
+		leave, ok := c.enter(rv)
+		if !ok {
+			return nil, fmt.Errorf("cyclic value at %s", displayPath(path))
+		}
+		defer leave()
+		return c.convertMap(rv, path)
+	case reflect.Func:
+		return c.convertFunc(rv, path)
+	case reflect.Struct:
+		table := c.L.NewTable()
+		if err := c.convertStruct(rv, table, path); err != nil {
+			return nil, err
+		}
+		return table, nil
+	default:
+		return nil, fmt.Errorf("unsupported type %s at %s", rv.Type(), displayPath(path))
+	}
+}
+
+func (c *goConverter) convertSequence(rv reflect.Value, path string) (lua.LValue, error) {
+	table := c.L.CreateTable(rv.Len(), 0)
+	for i := 0; i < rv.Len(); i++ {
+		value, err := c.convert(rv.Index(i), joinPath(path, lua.LNumber(i+1)))
+		if err != nil {
+			return nil, err
+		}
+		table.RawSetInt(i+1, value)
+	}
+	return table, nil
+}
+
+func (c *goConverter) convertMap(rv reflect.Value, path string) (lua.LValue, error) {
+	table := c.L.CreateTable(0, rv.Len())
+	iter := rv.MapRange()
+	for iter.Next() {
+		key, err := c.convert(iter.Key(), path)
+		if err != nil {
+			return nil, fmt.Errorf("unsupported map key: %w", err)
+		}
+		if key == lua.LNil {
+			continue
+		}
+		if _, ok := key.(*lua.LTable); ok {
+			return nil, fmt.Errorf("unsupported map key type %s at %s", iter.Key().Type(), displayPath(path))
+		}
+		value, err := c.convert(iter.Value(), joinPath(path, key))
+		if err != nil {
+			return nil, err
+		}
+		table.RawSet(key, value)
+	}
+	return table, nil
+}
+
+// convertStruct copies the exported fields of a struct into a table. Field names come from the
+// lua, mapstructure or json tag, in that order, falling back to the Go field name. A "-" name skips
+// the field, omitempty skips zero values and embedded structs without a name are flattened.
+func (c *goConverter) convertStruct(rv reflect.Value, table *lua.LTable, path string) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, omitEmpty, skip := fieldName(field)
+		if skip {
+			continue
+		}
+
+		fieldValue := rv.Field(i)
+		if omitEmpty && fieldValue.IsZero() {
+			continue
+		}
+
+		if field.Anonymous && name == "" {
+			flattened, err := c.flattenEmbedded(fieldValue, table, path)
+			if err != nil {
+				return err
+			}
+			if flattened {
+				continue
+			}
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		fieldPath := joinPath(path, lua.LString(name))
+		value, err := c.convert(fieldValue, fieldPath)
+		if err != nil {
+			return err
+		}
+		table.RawSetString(name, value)
+	}
+	return nil
+}
+
+// flattenEmbedded copies the fields of an embedded struct, or of the struct an embedded pointer
+// points to, into table. It reports false when the field is not a struct and must be converted as
+// a named field instead. Embedded pointers are tracked like any other pointer so a struct that
+// embeds itself is reported as cyclic.
+func (c *goConverter) flattenEmbedded(embedded reflect.Value, table *lua.LTable, path string) (bool, error) {
+	if embedded.Kind() == reflect.Pointer {
+		if embedded.IsNil() {
+			return true, nil
+		}
+		if embedded.Elem().Kind() != reflect.Struct {
+			return false, nil
+		}
+		leave, ok := c.enter(embedded)
+		if !ok {
+			return true, fmt.Errorf("cyclic value at %s", displayPath(path))
+		}
+		defer leave()
+		embedded = embedded.Elem()
+	}
+	if embedded.Kind() != reflect.Struct {
+		return false, nil
+	}
+	return true, c.convertStruct(embedded, table, path)
+}
+
+// fieldName returns the tagged name of a struct field and whether it is omitempty or skipped
+func fieldName(field reflect.StructField) (name string, omitEmpty bool, skip bool) {
+	for _, tagName := range []string{"lua", "mapstructure", "json"} {
+		tag, ok := field.Tag.Lookup(tagName)
+		if !ok {
+			continue
+		}
+		parts := strings.Split(tag, ",")
+		if parts[0] == "-" && len(parts) == 1 {
+			return "", false, true
+		}
+		for _, option := range parts[1:] {
+			if option == "omitempty" {
+				omitEmpty = true
+			}
+		}
+		return parts[0], omitEmpty, false
+	}
+	return "", false, false
+}
+
+// displayPath names the root of a conversion when the path is empty
+func displayPath(path string) string {
+	if path == "" {
+		return "top level"
+	}
+	return path
+}
+
+// TagName returns the config key given to a struct field by its lua, mapstructure or json tag,
+// empty when the field is untagged, and whether the tag skips the field
+func TagName(field reflect.StructField) (string, bool) {
+	name, _, skip := fieldName(field)
+	return name, skip
+}
@@ -0,0 +1,133 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yuin/gopher-lua"
+)
+
+func TestGoToLuaReflection(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	type Database struct {
+		Host     string `lua:"host"`
+		Port     uint16 `mapstructure:"port"`
+		Password string `lua:"-"`
+		Replica  *Database
+		Options  map[string]int `json:"options,omitempty"`
+	}
+	type Base struct {
+		Name string `lua:"name"`
+	}
+	type App struct {
+		Base
+		Database Database      `lua:"database"`
+		Timeout  time.Duration `lua:"timeout"`
+		Started  time.Time     `lua:"started"`
+		Tags     []string      `lua:"tags"`
+		Weights  [2]float32    `lua:"weights"`
+		Limits   map[int]int8  `lua:"limits"`
+		internal string
+	}
+
+	app := &App{
+		Base:     Base{Name: "culebra"},
+		Database: Database{Host: "localhost", Port: 5432, Password: "secret"},
+		Timeout:  90 * time.Second,
+		Started:  time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Tags:     []string{"a", "b"},
+		Weights:  [2]float32{0.5, 1.5},
+		Limits:   map[int]int8{1: 10},
+		internal: "hidden",
+	}
+
+	value, err := GoToLua(L, app)
+	if err != nil {
+		t.Fatalf("GoToLua failed: %v", err)
+	}
+	L.SetGlobal("app", value)
+
+	if err := L.DoString(`
+		assert(app.name == "culebra", "embedded field")
+		assert(app.database.host == "localhost", "lua tag")
+		assert(app.database.port == 5432, "mapstructure tag")
+		assert(app.database.Password == nil and app.database.password == nil, "skipped field")
+		assert(app.database.Replica == nil, "nil pointer")
+		assert(app.database.options == nil, "omitempty")
+		assert(app.timeout == "1m30s", "duration")
+		assert(app.started == "2024-01-02T03:04:05Z", "time")
+		assert(app.tags[2] == "b", "string slice")
+		assert(app.weights[2] == 1.5, "array")
+		assert(app.limits[1] == 10, "numeric map key")
+		assert(app.internal == nil, "unexported field")
+	`); err != nil {
+		t.Fatalf("Converted value mismatch: %v", err)
+	}
+}
+
+func TestGoToLuaNumericKinds(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	for _, input := range []any{int8(1), int16(1), int32(1), uint(1), uint8(1), uint32(1), uint64(1), float32(1)} {
+		value, err := GoToLua(L, input)
+		if err != nil {
+			t.Fatalf("GoToLua(%T) failed: %v", input, err)
+		}
+		if value != lua.LNumber(1) {
+			t.Errorf("GoToLua(%T) = %v, want 1", input, value)
+		}
+	}
+}
+
+func TestGoToLuaUnsupported(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	_, err := GoToLuaNamed(L, "settings", map[string]any{"events": make(chan int)})
+	if err == nil {
+		t.Fatal("Expected error for channel value")
+	}
+	if !strings.Contains(err.Error(), "chan int") || !strings.Contains(err.Error(), "settings.events") {
+		t.Errorf("Expected error to name the type and path, got %v", err)
+	}
+
+	type Node struct {
+		Next *Node
+	}
+	node := &Node{}
+	node.Next = node
+	if _, err := GoToLua(L, node); err == nil {
+		t.Error("Expected error for cyclic value")
+	}
+
+	type Embedding struct {
+		*Embedding
+		Name string
+	}
+	embedding := &Embedding{Name: "root"}
+	embedding.Embedding = embedding
+	if _, err := GoToLua(L, embedding); err == nil || !strings.Contains(err.Error(), "cyclic value") {
+		t.Errorf("Expected error for struct embedding itself, got %v", err)
+	}
+
+	cyclicMap := map[string]any{}
+	cyclicMap["self"] = cyclicMap
+	if _, err := GoToLuaNamed(L, "settings", cyclicMap); err == nil || !strings.Contains(err.Error(), "settings.self") {
+		t.Errorf("Expected error for cyclic map naming the path, got %v", err)
+	}
+
+	cyclicSlice := []any{nil}
+	cyclicSlice[0] = cyclicSlice
+	if _, err := GoToLua(L, cyclicSlice); err == nil {
+		t.Error("Expected error for cyclic slice")
+	}
+
+	shared := []any{"a"}
+	if _, err := GoToLua(L, map[string]any{"first": shared, "second": shared}); err != nil {
+		t.Errorf("Expected shared values that are not cyclic to convert, got %v", err)
+	}
+}
@@ -0,0 +1,68 @@
+package internal
+
+import (
+	"github.com/yuin/gopher-lua"
+)
+
+// markerField is set on the metatable of tables created by culebra.array and culebra.object
+const markerField = "__culebra"
+
+const (
+	arrayMarker  = lua.LString("array")
+	objectMarker = lua.LString("object")
+)
+
+// nullSentinel is the Go value held by the culebra.null userdata
+type nullSentinel struct{}
+
+// OpenSentinels adds null, array and object to a module table. culebra.null stores an explicit
+// null in a table, while culebra.array{...} and culebra.object{...} mark a table so it converts
+// to a slice or a map even when it is empty.
+func OpenSentinels(L *lua.LState, module *lua.LTable) {
+	null := L.NewUserData()
+	null.Value = nullSentinel{}
+	nullMeta := L.NewTable()
+	nullMeta.RawSetString("__tostring", L.NewFunction(func(L *lua.LState) int {
+		L.Push(lua.LString("culebra.null"))
+		return 1
+	}))
+	nullMeta.RawSetString("__metatable", lua.LFalse)
+	null.Metatable = nullMeta
+	module.RawSetString("null", null)
+
+	module.RawSetString("array", L.NewFunction(markTable(arrayMarker)))
+	module.RawSetString("object", L.NewFunction(markTable(objectMarker)))
+}
+
+// markTable returns a function that tags its table argument, or a new table, with a marker
+func markTable(marker lua.LString) lua.LGFunction {
+	return func(L *lua.LState) int {
+		table := L.OptTable(1, L.NewTable())
+		meta := L.NewTable()
+		meta.RawSetString(markerField, marker)
+		meta.RawSetString("__metatable", marker)
+		L.SetMetatable(table, meta)
+		L.Push(table)
+		return 1
+	}
+}
+
+// isNull reports whether a Lua value is the culebra.null sentinel
+func isNull(lv lua.LValue) bool {
+	ud, ok := lv.(*lua.LUserData)
+	if !ok {
+		return false
+	}
+	_, ok = ud.Value.(nullSentinel)
+	return ok
+}
+
+// tableMarker returns the marker set by culebra.array or culebra.object, or an empty string
+func tableMarker(table *lua.LTable) lua.LString {
+	meta, ok := table.Metatable.(*lua.LTable)
+	if !ok {
+		return ""
+	}
+	marker, _ := meta.RawGetString(markerField).(lua.LString)
+	return marker
+}
@@ -0,0 +1,61 @@
+package internal
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/yuin/gopher-lua"
+)
+
+func TestSentinels(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	module := L.NewTable()
+	OpenSentinels(L, module)
+	L.SetGlobal("culebra", module)
+
+	if err := L.DoString(`
+		config = {
+			password = culebra.null,
+			tags = culebra.array{},
+			hosts = culebra.array{ "a", "b" },
+			labels = culebra.object{},
+			ports = culebra.object{ 80, 443 },
+			values = { 1, culebra.null, 3 },
+		}
+		assert(tostring(culebra.null) == "culebra.null")
+	`); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := ConvertTable(L.GetGlobal("config").(*lua.LTable), Options{ConvertArrays: true})
+	if err != nil {
+		t.Fatalf("ConvertTable failed: %v", err)
+	}
+
+	expected := map[string]any{
+		"password": nil,
+		"tags":     []any{},
+		"hosts":    []any{"a", "b"},
+		"labels":   map[string]any{},
+		"ports":    map[string]any{"1": float64(80), "2": float64(443)},
+		"values":   []any{float64(1), nil, float64(3)},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Expected %v, got %v", expected, result)
+	}
+
+	if _, ok := result["password"]; !ok {
+		t.Error("Expected explicit null key to be present")
+	}
+
+	// Markers apply even when array conversion is disabled
+	result, err = ConvertTable(L.GetGlobal("config").(*lua.LTable), Options{})
+	if err != nil {
+		t.Fatalf("ConvertTable failed: %v", err)
+	}
+	if _, ok := result["tags"].([]any); !ok {
+		t.Errorf("Expected marked array to be a slice, got %T", result["tags"])
+	}
+}
@@ -0,0 +1,46 @@
+package internal
+
+import (
+	"github.com/yuin/gopher-lua"
+)
+
+// CopyTable returns a deep copy of an acyclic table, used to detect changes made in place.
+// Keys and values other than tables are shared, metatables are not copied.
+func CopyTable(table *lua.LTable) *lua.LTable {
+	result := &lua.LTable{}
+	table.ForEach(func(key, value lua.LValue) {
+		if nested, ok := value.(*lua.LTable); ok {
+			value = CopyTable(nested)
+		}
+		result.RawSet(key, value)
+	})
+	return result
+}
+
+// EqualTables reports whether table holds the same entries as snapshot, a copy made by CopyTable,
+// comparing nested tables by their contents
+func EqualTables(snapshot, table *lua.LTable) bool {
+	count := 0
+	equal := true
+	table.ForEach(func(key, value lua.LValue) {
+		if !equal {
+			return
+		}
+		count++
+		previous := snapshot.RawGet(key)
+		previousTable, wasTable := previous.(*lua.LTable)
+		currentTable, isTable := value.(*lua.LTable)
+		if wasTable && isTable {
+			equal = EqualTables(previousTable, currentTable)
+			return
+		}
+		equal = previous == value
+	})
+	if !equal {
+		return false
+	}
+
+	snapshotCount := 0
+	snapshot.ForEach(func(lua.LValue, lua.LValue) { snapshotCount++ })
+	return count == snapshotCount
+}
@@ -0,0 +1,43 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/yuin/gopher-lua"
+)
+
+func TestEqualTables(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	tests := []struct {
+		name   string
+		change string
+		equal  bool
+	}{
+		{"unchanged", ``, true},
+		{"reassigned same value", `defaults.port = 8080`, true},
+		{"changed value", `defaults.port = 9090`, false},
+		{"added key", `defaults.debug = true`, false},
+		{"removed key", `defaults.port = nil`, false},
+		{"nested change", `defaults.tls.enabled = true`, false},
+		{"nested table replaced", `defaults.tls = { enabled = false }`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := L.DoString(`defaults = { port = 8080, tls = { enabled = false } }`); err != nil {
+				t.Fatal(err)
+			}
+			table := L.GetGlobal("defaults").(*lua.LTable)
+			snapshot := CopyTable(table)
+
+			if err := L.DoString(tt.change); err != nil {
+				t.Fatal(err)
+			}
+			if got := EqualTables(snapshot, table); got != tt.equal {
+				t.Errorf("Expected EqualTables = %v, got %v", tt.equal, got)
+			}
+		})
+	}
+}
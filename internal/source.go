@@ -0,0 +1,169 @@
+package internal
+
+import (
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/yuin/gopher-lua/ast"
+	"github.com/yuin/gopher-lua/parse"
+)
+
+// localRoot prefixes the paths of keys assigned through a local variable
+const localRoot = "\x00"
+
+// KeyLines parses a Lua config and maps the dotted keys it assigns to the line of the assignment.
+// Only literal keys assigned at the top level of the chunk are found: table constructors, dotted
+// assignments and the table passed to a call such as setmetatable. Keys set through a local are
+// reported relative to the config when the chunk returns that local.
+func KeyLines(source io.Reader, name string) (map[string]int, error) {
+	chunk, err := parse.Parse(source, name)
+	if err != nil {
+		return nil, err
+	}
+
+	index := &keyIndex{lines: make(map[string]int), locals: make(map[string]bool)}
+	index.walkBlock(chunk)
+
+	lines := make(map[string]int)
+	for path, line := range index.lines {
+		if !strings.HasPrefix(path, localRoot) {
+			lines[path] = line
+		}
+	}
+	for path, line := range index.returned {
+		lines[path] = line
+	}
+	return lines, nil
+}
+
+// keyIndex records the lines of key assignments while walking a chunk
+type keyIndex struct {
+	lines    map[string]int
+	locals   map[string]bool
+	returned map[string]int
+}
+
+// walkBlock records the assignments of a block, skipping loops and function bodies
+func (k *keyIndex) walkBlock(stmts []ast.Stmt) {
+	for _, stmt := range stmts {
+		switch s := stmt.(type) {
+		case *ast.LocalAssignStmt:
+			for i, name := range s.Names {
+				k.locals[name] = true
+				if i < len(s.Exprs) {
+					k.walkExpr(localRoot+name, s.Exprs[i])
+				}
+			}
+		case *ast.AssignStmt:
+			for i, lhs := range s.Lhs {
+				if path, ok := k.target(lhs); ok && i < len(s.Rhs) {
+					k.record(path, s.Rhs[i].Line())
+					k.walkExpr(path, s.Rhs[i])
+				}
+			}
+		case *ast.DoBlockStmt:
+			k.walkBlock(s.Stmts)
+		case *ast.IfStmt:
+			k.walkBlock(s.Then)
+			k.walkBlock(s.Else)
+		case *ast.ReturnStmt:
+			if len(s.Exprs) > 0 {
+				k.walkReturn(s.Exprs[0])
+			}
+		}
+	}
+}
+
+// walkReturn records the keys of the returned config relative to the top level
+func (k *keyIndex) walkReturn(expr ast.Expr) {
+	if call, ok := expr.(*ast.FuncCallExpr); ok && len(call.Args) > 0 {
+		expr = call.Args[0]
+	}
+
+	k.returned = make(map[string]int)
+	switch e := expr.(type) {
+	case *ast.IdentExpr:
+		if !k.locals[e.Value] {
+			return
+		}
+		prefix := localRoot + e.Value + "."
+		for path, line := range k.lines {
+			if strings.HasPrefix(path, prefix) {
+				k.returned[strings.TrimPrefix(path, prefix)] = line
+			}
+		}
+	case *ast.TableExpr:
+		lines := k.lines
+		k.lines = k.returned
+		k.walkExpr("", e)
+		k.lines = lines
+	}
+}
+
+// walkExpr records the keys of a table constructor assigned to path
+func (k *keyIndex) walkExpr(path string, expr ast.Expr) {
+	switch e := expr.(type) {
+	case *ast.FuncCallExpr:
+		if len(e.Args) > 0 {
+			k.walkExpr(path, e.Args[0])
+		}
+	case *ast.TableExpr:
+		position := 0
+		for _, field := range e.Fields {
+			var key string
+			switch fieldKey := field.Key.(type) {
+			case nil:
+				position++
+				key = strconv.Itoa(position)
+			case *ast.StringExpr:
+				key = fieldKey.Value
+			case *ast.NumberExpr:
+				key = fieldKey.Value
+			default:
+				continue
+			}
+			fieldPath := joinKey(path, key)
+			k.record(fieldPath, field.Value.Line())
+			k.walkExpr(fieldPath, field.Value)
+		}
+	}
+}
+
+// target returns the path assigned by the left-hand side of an assignment
+func (k *keyIndex) target(expr ast.Expr) (string, bool) {
+	switch e := expr.(type) {
+	case *ast.IdentExpr:
+		if k.locals[e.Value] {
+			return localRoot + e.Value, true
+		}
+		return e.Value, true
+	case *ast.AttrGetExpr:
+		object, ok := k.target(e.Object)
+		if !ok {
+			return "", false
+		}
+		switch key := e.Key.(type) {
+		case *ast.StringExpr:
+			return joinKey(object, key.Value), true
+		case *ast.NumberExpr:
+			return joinKey(object, key.Value), true
+		}
+	}
+	return "", false
+}
+
+// record keeps the last line assigning path
+func (k *keyIndex) record(path string, line int) {
+	if path != "" {
+		k.lines[path] = line
+	}
+}
+
+// joinKey appends a key name to a dotted path
+func joinKey(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
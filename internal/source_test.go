@@ -0,0 +1,49 @@
+package internal
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestKeyLines(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		expected map[string]int
+	}{
+		{
+			name:     "globals",
+			source:   "name = \"app\"\nserver = {\n  port = 80,\n}\nserver.host = \"localhost\"\n",
+			expected: map[string]int{"name": 1, "server": 2, "server.port": 3, "server.host": 5},
+		},
+		{
+			name:     "returned table",
+			source:   "return {\n  level = \"info\",\n  hosts = { \"a\",\n    \"b\" },\n}\n",
+			expected: map[string]int{"level": 2, "hosts": 3, "hosts.1": 3, "hosts.2": 4},
+		},
+		{
+			name:     "returned local",
+			source:   "local config = { port = 80 }\nconfig.debug = true\nreturn setmetatable(config, {})\n",
+			expected: map[string]int{"port": 1, "debug": 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines, err := KeyLines(strings.NewReader(tt.source), "test.lua")
+			if err != nil {
+				t.Fatalf("KeyLines failed: %v", err)
+			}
+			if !reflect.DeepEqual(lines, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, lines)
+			}
+		})
+	}
+}
+
+func TestKeyLinesSyntaxError(t *testing.T) {
+	if _, err := KeyLines(strings.NewReader("config = {"), "test.lua"); err == nil {
+		t.Error("Expected error for invalid Lua")
+	}
+}
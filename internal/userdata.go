@@ -0,0 +1,157 @@
+package internal
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/yuin/gopher-lua"
+)
+
+// userdataMetatable is the registry name of the metatable shared by exposed Go values
+const userdataMetatable = "culebra.userdata"
+
+// Userdata marks a Go value that GoToLua exposes as userdata instead of copying it into tables
+type Userdata struct {
+	Value    any
+	Writable bool
+}
+
+// exposedValue is stored in the LUserData of an exposed Go value
+type exposedValue struct {
+	value    reflect.Value
+	writable bool
+	path     string
+}
+
+var userdataType = reflect.TypeOf(Userdata{})
+
+// exposeUserdata wraps a Go value in userdata whose metatable resolves exported fields and
+// methods through reflection. Writes raise an error unless the value is writable.
+func exposeUserdata(L *lua.LState, rv reflect.Value, writable bool, path string) lua.LValue {
+	if !rv.IsValid() || ((rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface) && rv.IsNil()) {
+		return lua.LNil
+	}
+
+	ud := L.NewUserData()
+	ud.Value = &exposedValue{value: rv, writable: writable, path: path}
+	ud.Metatable = userdataMeta(L)
+	return ud
+}
+
+// userdataMeta returns the metatable shared by all exposed values of a Lua state
+func userdataMeta(L *lua.LState) lua.LValue {
+	if meta, ok := L.GetField(L.Get(lua.RegistryIndex), userdataMetatable).(*lua.LTable); ok {
+		return meta
+	}
+
+	meta := L.NewTypeMetatable(userdataMetatable)
+	meta.RawSetString("__index", L.NewFunction(userdataIndex))
+	meta.RawSetString("__newindex", L.NewFunction(userdataNewIndex))
+	meta.RawSetString("__tostring", L.NewFunction(func(L *lua.LState) int {
+		exposed := checkExposed(L)
+		L.Push(lua.LString(fmt.Sprintf("%s: %v", exposed.value.Type(), exposed.value.Interface())))
+		return 1
+	}))
+	return meta
+}
+
+func checkExposed(L *lua.LState) *exposedValue {
+	ud := L.CheckUserData(1)
+	exposed, ok := ud.Value.(*exposedValue)
+	if !ok {
+		L.ArgError(1, "exposed Go value expected")
+	}
+	return exposed
+}
+
+func userdataIndex(L *lua.LState) int {
+	ud := L.CheckUserData(1)
+	exposed := checkExposed(L)
+	key := L.CheckString(2)
+	path := joinPath(exposed.path, lua.LString(key))
+
+	if method := exposed.value.MethodByName(key); method.IsValid() {
+		L.Push(L.NewFunction(reflectFunction(method, displayPath(path), ud)))
+		return 1
+	}
+
+	field, ok := exposedField(exposed.value, key)
+	if !ok {
+		L.Push(lua.LNil)
+		return 1
+	}
+
+	// Nested structs stay exposed so they are not copied either
+	target := field
+	if target.Kind() == reflect.Pointer {
+		target = target.Elem()
+	}
+	if target.Kind() == reflect.Struct && target.Type() != timeType {
+		if field.Kind() != reflect.Pointer && field.CanAddr() {
+			field = field.Addr()
+		}
+		L.Push(exposeUserdata(L, field, exposed.writable, path))
+		return 1
+	}
+
+	value, err := newGoConverter(L).convert(field, path)
+	if err != nil {
+		L.RaiseError("%v", err)
+	}
+	L.Push(value)
+	return 1
+}
+
+func userdataNewIndex(L *lua.LState) int {
+	exposed := checkExposed(L)
+	key := L.CheckString(2)
+	path := joinPath(exposed.path, lua.LString(key))
+
+	if !exposed.writable {
+		L.RaiseError("attempt to modify read-only field '%s'", displayPath(path))
+	}
+
+	field, ok := exposedField(exposed.value, key)
+	if !ok || !field.CanSet() {
+		L.RaiseError("cannot assign field '%s'", displayPath(path))
+	}
+
+	value, err := LuaToReflect(L.Get(3), field.Type())
+	if err != nil {
+		L.RaiseError("cannot assign field '%s': %v", displayPath(path), err)
+	}
+	field.Set(value)
+	return 0
+}
+
+// exposedField finds an exported struct field by its Go name or tagged name, including fields
+// promoted from embedded structs like Go field selectors do
+func exposedField(rv reflect.Value, key string) (reflect.Value, bool) {
+	for rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return reflect.Value{}, false
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return reflect.Value{}, false
+	}
+
+	for _, field := range reflect.VisibleFields(rv.Type()) {
+		if !field.IsExported() {
+			continue
+		}
+		name, _, skip := fieldName(field)
+		if skip {
+			continue
+		}
+		if field.Name == key || name == key {
+			value, err := rv.FieldByIndexErr(field.Index)
+			if err != nil {
+				return reflect.Value{}, false
+			}
+			return value, true
+		}
+	}
+	return reflect.Value{}, false
+}
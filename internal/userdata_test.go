@@ -0,0 +1,144 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/yuin/gopher-lua"
+)
+
+type testHost struct {
+	Name    string `lua:"name"`
+	Cores   int
+	Network *testNetwork
+	secret  string
+}
+
+type testNetwork struct {
+	Interface string
+	MTU       int
+}
+
+func (h *testHost) CPUCount() int {
+	return h.Cores
+}
+
+func (h *testHost) Describe(prefix string) string {
+	return prefix + h.Name
+}
+
+func TestExposeUserdata(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	host := &testHost{Name: "web-1", Cores: 8, Network: &testNetwork{Interface: "eth0", MTU: 1500}, secret: "x"}
+
+	value, err := GoToLuaNamed(L, "host", Userdata{Value: host})
+	if err != nil {
+		t.Fatalf("GoToLua failed: %v", err)
+	}
+	if value.Type() != lua.LTUserData {
+		t.Fatalf("Expected userdata, got %v", value.Type())
+	}
+	L.SetGlobal("host", value)
+
+	if err := L.DoString(`
+		assert(host.name == "web-1", "tagged field")
+		assert(host.Name == "web-1", "field by Go name")
+		assert(host.CPUCount() == 8, "method with dot syntax")
+		assert(host:CPUCount() == 8, "method with colon syntax")
+		assert(host:Describe("host ") == "host web-1", "method arguments")
+		assert(host.Network.MTU == 1500, "nested struct")
+		assert(host.secret == nil, "unexported field")
+		assert(host.missing == nil, "unknown field")
+	`); err != nil {
+		t.Fatalf("Accessing exposed value failed: %v", err)
+	}
+
+	err = L.DoString(`host.Network.MTU = 9000`)
+	if err == nil || !strings.Contains(err.Error(), "host.Network.MTU") {
+		t.Errorf("Expected read-only error naming the field, got %v", err)
+	}
+
+	// Changes on the Go side are visible without converting again
+	host.Cores = 16
+	if err := L.DoString(`assert(host.CPUCount() == 16)`); err != nil {
+		t.Errorf("Expected exposed value to be shared, got %v", err)
+	}
+}
+
+func TestExposeUserdataWritable(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	host := &testHost{Name: "web-1", Network: &testNetwork{}}
+
+	value, err := GoToLuaNamed(L, "host", Userdata{Value: host, Writable: true})
+	if err != nil {
+		t.Fatalf("GoToLua failed: %v", err)
+	}
+	L.SetGlobal("host", value)
+
+	if err := L.DoString(`
+		host.Cores = 4
+		host.Network.MTU = 9000
+	`); err != nil {
+		t.Fatalf("Assigning exposed fields failed: %v", err)
+	}
+
+	if host.Cores != 4 || host.Network.MTU != 9000 {
+		t.Errorf("Expected assignments to reach the Go value, got %+v %+v", host, host.Network)
+	}
+
+	if err := L.DoString(`host.Cores = "many"`); err == nil {
+		t.Error("Expected error assigning a value of the wrong type")
+	}
+}
+
+type testBase struct {
+	Name   string `lua:"name"`
+	Region string
+}
+
+type testService struct {
+	testBase
+	*testNetwork
+	Region string
+	Port   int
+}
+
+func TestExposeUserdataPromotedFields(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	service := &testService{
+		testBase:    testBase{Name: "api", Region: "embedded"},
+		testNetwork: &testNetwork{MTU: 1500},
+		Region:      "outer",
+		Port:        8080,
+	}
+
+	value, err := GoToLuaNamed(L, "service", Userdata{Value: service, Writable: true})
+	if err != nil {
+		t.Fatalf("GoToLua failed: %v", err)
+	}
+	L.SetGlobal("service", value)
+
+	if err := L.DoString(`
+		assert(service.name == "api", "promoted tagged field")
+		assert(service.Name == "api", "promoted field by Go name")
+		assert(service.MTU == 1500, "field promoted through a pointer")
+		assert(service.Region == "outer", "outer field shadows the promoted one")
+		service.Name = "web"
+	`); err != nil {
+		t.Fatalf("Accessing promoted fields failed: %v", err)
+	}
+	if service.Name != "web" {
+		t.Errorf("Expected assignment to reach the promoted field, got %q", service.Name)
+	}
+
+	service.testNetwork = nil
+	if err := L.DoString(`assert(service.MTU == nil)`); err != nil {
+		t.Errorf("Expected field behind a nil embedded pointer to be nil, got %v", err)
+	}
+}
@@ -0,0 +1,328 @@
+package culebra
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"sort"
+)
+
+// JSONSchemaDialect is the $schema URI of generated JSON Schemas
+const JSONSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// supportedJSONSchemaKeywords are the validation keywords ParseJSONSchema enforces
+var supportedJSONSchemaKeywords = []string{
+	"type", "enum", "properties", "required", "additionalProperties", "items", "pattern",
+	"minimum", "maximum", "minLength", "maxLength", "minItems", "maxItems",
+}
+
+// jsonSchemaAnnotations are keywords that do not affect validation and are ignored. $defs only
+// holds schemas for $ref, which is not supported, and format is an annotation in draft 2020-12.
+var jsonSchemaAnnotations = []string{
+	"$schema", "$id", "$anchor", "$dynamicAnchor", "$vocabulary", "$comment", "$defs", "definitions",
+	"title", "description", "default", "examples", "deprecated", "readOnly", "writeOnly",
+	"format", "contentEncoding", "contentMediaType", "contentSchema",
+}
+
+// ParseJSONSchema reads a JSON Schema document into a Schema. The draft 2020-12 subset made of
+// type, properties, required, enum, minimum, maximum, minLength, maxLength, minItems, maxItems,
+// pattern, items and additionalProperties is supported; annotations such as title, description
+// and format are ignored and every other keyword is reported as an error. Like in JSON Schema, the keywords of a
+// schema without a type only apply to values of their own type, e.g. minimum to numbers, and
+// those belonging to another type than the declared one, e.g. maxLength on an integer, are ignored.
+func ParseJSONSchema(data []byte) (*Schema, error) {
+	var document any
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("failed to parse json schema: %w", err)
+	}
+	return schemaFromJSON(document, "")
+}
+
+// schemaFromJSON converts a decoded JSON Schema, path names the schema location in errors
+func schemaFromJSON(document any, path string) (*Schema, error) {
+	switch v := document.(type) {
+	case bool:
+		if !v {
+			return nil, fmt.Errorf("false schema at %s is not supported", displaySchemaPath(path))
+		}
+		return &Schema{}, nil
+	case map[string]any:
+		return schemaFromJSONObject(v, path)
+	default:
+		return nil, fmt.Errorf("json schema at %s must be an object or a boolean", displaySchemaPath(path))
+	}
+}
+
+// schemaFromJSONObject converts the keywords of a JSON Schema object
+func schemaFromJSONObject(document map[string]any, path string) (*Schema, error) {
+	keywords := slices.Sorted(maps.Keys(document))
+	for _, keyword := range keywords {
+		if !slices.Contains(supportedJSONSchemaKeywords, keyword) && !slices.Contains(jsonSchemaAnnotations, keyword) {
+			return nil, fmt.Errorf("unsupported json schema keyword %q at %s", keyword, displaySchemaPath(path))
+		}
+	}
+
+	schema := &Schema{}
+	if err := parseJSONType(schema, document["type"]); err != nil {
+		return nil, fmt.Errorf("invalid type at %s: %w", displaySchemaPath(path), err)
+	}
+	if _, ok := document["type"]; !ok {
+		schema.keywordType = keywordType(document)
+	}
+
+	if enum, ok := document["enum"]; ok {
+		members, ok := enum.([]any)
+		if !ok {
+			return nil, fmt.Errorf("enum at %s must be an array", displaySchemaPath(path))
+		}
+		schema.Enum = members
+	}
+
+	// Min, Max and Items hold the keywords of one type, those of other types never apply to the
+	// values the schema accepts and are ignored
+	constrained := schema.Type
+	if constrained == TypeAny {
+		constrained = schema.keywordType
+	}
+	minKeyword, maxKeyword := boundKeywords(constrained)
+	for _, bound := range []struct {
+		keyword string
+		target  **float64
+	}{
+		{minKeyword, &schema.Min}, {maxKeyword, &schema.Max},
+	} {
+		if bound.keyword == "" {
+			continue
+		}
+		value, ok := document[bound.keyword]
+		if !ok {
+			continue
+		}
+		number, ok := value.(float64)
+		if !ok {
+			return nil, fmt.Errorf("%s at %s must be a number", bound.keyword, displaySchemaPath(path))
+		}
+		*bound.target = &number
+	}
+
+	if pattern, ok := document["pattern"]; ok {
+		text, ok := pattern.(string)
+		if !ok {
+			return nil, fmt.Errorf("pattern at %s must be a string", displaySchemaPath(path))
+		}
+		if _, err := regexp.Compile(text); err != nil {
+			return nil, fmt.Errorf("invalid pattern at %s: %w", displaySchemaPath(path), err)
+		}
+		schema.Pattern = text
+	}
+
+	if items, ok := document["items"]; ok && constrained == TypeArray {
+		itemSchema, err := schemaFromJSON(items, joinSchemaPath(path, "items"))
+		if err != nil {
+			return nil, err
+		}
+		schema.Items = itemSchema
+	}
+
+	if properties, ok := document["properties"]; ok {
+		fields, ok := properties.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("properties at %s must be an object", displaySchemaPath(path))
+		}
+		schema.Fields = make(map[string]*Schema, len(fields))
+		for name, property := range fields {
+			field, err := schemaFromJSON(property, joinSchemaPath(path, name))
+			if err != nil {
+				return nil, err
+			}
+			schema.Fields[name] = field
+		}
+	}
+
+	if required, ok := document["required"]; ok {
+		names, ok := required.([]any)
+		if !ok {
+			return nil, fmt.Errorf("required at %s must be an array", displaySchemaPath(path))
+		}
+		for _, name := range names {
+			key, ok := name.(string)
+			if !ok {
+				return nil, fmt.Errorf("required at %s must list strings", displaySchemaPath(path))
+			}
+			if schema.Fields == nil {
+				schema.Fields = make(map[string]*Schema)
+			}
+			if schema.Fields[key] == nil {
+				schema.Fields[key] = &Schema{}
+			}
+			schema.Fields[key].Required = true
+		}
+	}
+
+	switch additional := document["additionalProperties"].(type) {
+	case nil:
+	case bool:
+		schema.Closed = !additional
+	default:
+		if constrained == TypeArray {
+			break
+		}
+		values, err := schemaFromJSON(additional, joinSchemaPath(path, "additionalProperties"))
+		if err != nil {
+			return nil, err
+		}
+		schema.Items = values
+	}
+
+	return schema, nil
+}
+
+// jsonTypeKeywords lists the keywords that only apply to values of one type
+var jsonTypeKeywords = map[string][]string{
+	TypeObject: {"properties", "required", "additionalProperties"},
+	TypeArray:  {"items", "minItems", "maxItems"},
+	TypeString: {"pattern", "minLength", "maxLength"},
+	TypeNumber: {"minimum", "maximum"},
+}
+
+// keywordType returns the type of the values the keywords of a schema without a type keyword
+// apply to, so that {"minimum": 1} checks numbers and accepts every other value. It returns
+// TypeAny when the keywords apply to no type or to several.
+func keywordType(document map[string]any) string {
+	found := TypeAny
+	for schemaType, keywords := range jsonTypeKeywords {
+		for _, keyword := range keywords {
+			if _, ok := document[keyword]; !ok {
+				continue
+			}
+			if found != TypeAny && found != schemaType {
+				return TypeAny
+			}
+			found = schemaType
+		}
+	}
+	return found
+}
+
+// boundKeywords returns the JSON Schema keywords holding Min and Max for a schema type, empty
+// for types without bounds
+func boundKeywords(schemaType string) (string, string) {
+	switch schemaType {
+	case TypeNumber, TypeInteger:
+		return "minimum", "maximum"
+	case TypeString:
+		return "minLength", "maxLength"
+	case TypeArray:
+		return "minItems", "maxItems"
+	default:
+		return "", ""
+	}
+}
+
+// parseJSONType reads the type keyword, a type name or a list of names where "null" is ignored
+func parseJSONType(schema *Schema, value any) error {
+	var names []string
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case string:
+		names = []string{v}
+	case []any:
+		for _, item := range v {
+			name, ok := item.(string)
+			if !ok {
+				return fmt.Errorf("type names must be strings")
+			}
+			if name != "null" {
+				names = append(names, name)
+			}
+		}
+	default:
+		return fmt.Errorf("type must be a string or an array")
+	}
+
+	if len(names) != 1 {
+		return nil
+	}
+	switch names[0] {
+	case TypeString, TypeNumber, TypeInteger, TypeBoolean, TypeArray, TypeObject:
+		schema.Type = names[0]
+		return nil
+	default:
+		return fmt.Errorf("unknown type %q", names[0])
+	}
+}
+
+// JSONSchema returns the schema as a JSON Schema document
+func (s *Schema) JSONSchema() map[string]any {
+	document := map[string]any{}
+	if s.Type != TypeAny {
+		document["type"] = s.Type
+	}
+	if len(s.Enum) > 0 {
+		document["enum"] = s.Enum
+	}
+
+	constrained := s.Type
+	if constrained == TypeAny {
+		constrained = s.keywordType
+	}
+
+	minKeyword, maxKeyword := boundKeywords(constrained)
+	if minKeyword == "" {
+		minKeyword, maxKeyword = "minimum", "maximum"
+	}
+	if s.Min != nil {
+		document[minKeyword] = *s.Min
+	}
+	if s.Max != nil {
+		document[maxKeyword] = *s.Max
+	}
+	if s.Pattern != "" {
+		document["pattern"] = s.Pattern
+	}
+
+	if constrained == TypeArray {
+		if s.Items != nil {
+			document["items"] = s.Items.JSONSchema()
+		}
+		return document
+	}
+
+	if len(s.Fields) > 0 {
+		properties := make(map[string]any, len(s.Fields))
+		var required []string
+		for name, field := range s.Fields {
+			properties[name] = field.JSONSchema()
+			if field.Required {
+				required = append(required, name)
+			}
+		}
+		document["properties"] = properties
+		if len(required) > 0 {
+			sort.Strings(required)
+			document["required"] = required
+		}
+	}
+	if s.Closed {
+		document["additionalProperties"] = false
+	} else if s.Items != nil {
+		document["additionalProperties"] = s.Items.JSONSchema()
+	}
+
+	return document
+}
+
+// JSONSchemaOf generates an indented JSON Schema document from a Go config struct, see SchemaOf
+func JSONSchemaOf(v any) ([]byte, error) {
+	schema, err := SchemaOf(v)
+	if err != nil {
+		return nil, err
+	}
+
+	document := schema.JSONSchema()
+	document["$schema"] = JSONSchemaDialect
+	return json.MarshalIndent(document, "", "  ")
+}
@@ -0,0 +1,175 @@
+package culebra
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const serviceJSONSchema = `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "title": "Service",
+  "type": "object",
+  "required": ["name", "port"],
+  "additionalProperties": false,
+  "properties": {
+    "name": { "type": "string", "pattern": "^[a-z]+$", "maxLength": 8 },
+    "port": { "type": "integer", "minimum": 1, "maximum": 65535 },
+    "level": { "enum": ["debug", "info"] },
+    "hosts": { "type": "array", "items": { "type": "string" }, "minItems": 1 },
+    "labels": { "type": ["object", "null"], "additionalProperties": { "type": "string" } }
+  }
+}`
+
+func TestParseJSONSchema(t *testing.T) {
+	schema, err := ParseJSONSchema([]byte(serviceJSONSchema))
+	if err != nil {
+		t.Fatalf("ParseJSONSchema failed: %v", err)
+	}
+
+	data := map[string]any{
+		"name":   "Web-1",
+		"level":  "trace",
+		"hosts":  []any{},
+		"labels": map[string]any{"team": 1},
+		"extra":  true,
+	}
+
+	err = Validate(data, schema)
+	var errs ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("Expected ValidationErrors, got %v", err)
+	}
+
+	var paths []string
+	for _, e := range errs {
+		paths = append(paths, e.Path)
+	}
+	expected := []string{"extra", "hosts", "labels.team", "level", "name", "port"}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Errorf("Expected violations at %v, got %v", expected, paths)
+	}
+
+	valid := map[string]any{"name": "web", "port": float64(80), "hosts": []any{"a"}}
+	if err := Validate(valid, schema); err != nil {
+		t.Errorf("Expected valid config, got %v", err)
+	}
+}
+
+func TestParseJSONSchemaUnsupported(t *testing.T) {
+	_, err := ParseJSONSchema([]byte(`{"properties": {"db": {"$ref": "#/$defs/db"}}}`))
+	if err == nil || !strings.Contains(err.Error(), "$ref") || !strings.Contains(err.Error(), "db") {
+		t.Errorf("Expected error naming the keyword and location, got %v", err)
+	}
+}
+
+func TestParseJSONSchemaRejectsUnknownKeywords(t *testing.T) {
+	for _, keyword := range []string{"multipleOf", "uniqueItems", "minProperties", "maxProperties", "unevaluatedProperties", "exclusiveMinimum"} {
+		_, err := ParseJSONSchema([]byte(`{"type": "integer", "` + keyword + `": 5}`))
+		if err == nil || !strings.Contains(err.Error(), keyword) {
+			t.Errorf("Expected error naming %s, got %v", keyword, err)
+		}
+	}
+
+	if _, err := ParseJSONSchema([]byte(`{"type": "string", "format": "email", "title": "Email", "$comment": "x"}`)); err != nil {
+		t.Errorf("Expected annotations to be ignored, got %v", err)
+	}
+}
+
+func TestParseJSONSchemaBoundsOfOtherTypes(t *testing.T) {
+	schema, err := ParseJSONSchema([]byte(`{"properties": {
+  "count": {"type": "integer", "minimum": 1, "maxLength": 3},
+  "name": {"type": "string", "maxLength": 3, "maximum": 100},
+  "tags": {"type": "array", "maxItems": 2, "additionalProperties": false}
+}}`))
+	if err != nil {
+		t.Fatalf("ParseJSONSchema failed: %v", err)
+	}
+
+	if err := Validate(map[string]any{"count": float64(7), "name": "abc", "tags": []any{"a"}}, schema); err != nil {
+		t.Errorf("Expected keywords of other types to be ignored, got %v", err)
+	}
+	err = Validate(map[string]any{"count": float64(0), "name": "abcd", "tags": []any{"a", "b", "c"}}, schema)
+	var errs ValidationErrors
+	if !errors.As(err, &errs) || len(errs) != 3 {
+		t.Errorf("Expected minimum, maxLength and maxItems violations, got %v", err)
+	}
+}
+
+func TestJSONSchemaOf(t *testing.T) {
+	encoded, err := JSONSchemaOf(appSchema{})
+	if err != nil {
+		t.Fatalf("JSONSchemaOf failed: %v", err)
+	}
+
+	var document map[string]any
+	if err := json.Unmarshal(encoded, &document); err != nil {
+		t.Fatalf("Generated schema is not valid JSON: %v", err)
+	}
+	if document["$schema"] != JSONSchemaDialect {
+		t.Errorf("Expected dialect, got %v", document["$schema"])
+	}
+
+	port := document["properties"].(map[string]any)["server"].(map[string]any)["properties"].(map[string]any)["port"]
+	expectedPort := map[string]any{"type": "integer", "minimum": float64(1), "maximum": float64(65535)}
+	if !reflect.DeepEqual(port, expectedPort) {
+		t.Errorf("Expected %v, got %v", expectedPort, port)
+	}
+
+	roundTrip, err := ParseJSONSchema(encoded)
+	if err != nil {
+		t.Fatalf("ParseJSONSchema failed on generated schema: %v", err)
+	}
+	original, _ := SchemaOf(appSchema{})
+	if !reflect.DeepEqual(roundTrip, original) {
+		t.Errorf("Expected round trip to preserve the schema\nwant %+v\ngot  %+v", original, roundTrip)
+	}
+}
+
+func TestParseJSONSchemaWithoutType(t *testing.T) {
+	schema, err := ParseJSONSchema([]byte(`{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "required": ["name"],
+  "properties": {
+    "name": { "pattern": "^[a-z]+$" },
+    "port": { "minimum": 1 },
+    "mixed": { "minimum": 1, "minLength": 2 }
+  }
+}`))
+	if err != nil {
+		t.Fatalf("ParseJSONSchema failed: %v", err)
+	}
+	if schema.Type != TypeAny || schema.Fields["name"].Type != TypeAny || schema.Fields["port"].Type != TypeAny {
+		t.Errorf("Expected types to stay open, got %+v", schema)
+	}
+
+	err = Validate(map[string]any{"port": float64(0)}, schema)
+	var errs ValidationErrors
+	if !errors.As(err, &errs) || len(errs) != 2 {
+		t.Fatalf("Expected required and minimum violations, got %v", err)
+	}
+	if err := Validate(map[string]any{"name": "Web"}, schema); err == nil {
+		t.Error("Expected pattern violation")
+	}
+	if err := Validate(map[string]any{"name": "web", "port": float64(80)}, schema); err != nil {
+		t.Errorf("Expected valid config, got %v", err)
+	}
+	if err := Validate(map[string]any{"name": 5, "port": "80", "mixed": true}, schema); err != nil {
+		t.Errorf("Expected keywords to ignore values of other types, got %v", err)
+	}
+}
+
+func TestValidateLengthCountsCharacters(t *testing.T) {
+	schema, err := ParseJSONSchema([]byte(`{"properties": {"name": {"type": "string", "maxLength": 3}}}`))
+	if err != nil {
+		t.Fatalf("ParseJSONSchema failed: %v", err)
+	}
+	if err := Validate(map[string]any{"name": "héé"}, schema); err != nil {
+		t.Errorf("Expected length in characters, got %v", err)
+	}
+	if err := Validate(map[string]any{"name": "héé!"}, schema); err == nil {
+		t.Error("Expected maxLength violation")
+	}
+}
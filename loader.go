@@ -0,0 +1,114 @@
+package culebra
+
+import (
+	"fmt"
+
+	"github.com/Fuabioo/culebra/internal"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// Loader loads Lua configs with Go-backed modules available through require
+type Loader struct {
+	cfg Config
+}
+
+// module is a Go-backed Lua module registered with Loader.RegisterModule
+type module struct {
+	funcs  map[string]any
+	fields map[string]any
+}
+
+// NewLoader creates a Loader for the given config
+func NewLoader(cfg Config) *Loader {
+	modules := make(map[string]module, len(cfg.modules))
+	for name, m := range cfg.modules {
+		modules[name] = m
+	}
+	cfg.modules = modules
+	return &Loader{cfg: cfg}
+}
+
+// RegisterModule makes a Go-backed module available to the config as require(name).
+// Entries of funcs become module functions and entries of fields become module values;
+// both are converted like Config.Globals, so typed Go functions and structs are supported.
+func (l *Loader) RegisterModule(name string, funcs map[string]any, fields map[string]any) {
+	l.cfg.modules[name] = module{funcs: funcs, fields: fields}
+}
+
+// Load loads the config with the registered modules, see Load
+func (l *Loader) Load() (map[string]any, error) {
+	return Load(l.cfg)
+}
+
+// LoadOrdered loads the config with the registered modules, see LoadOrdered
+func (l *Loader) LoadOrdered() (*OrderedMap, error) {
+	return LoadOrdered(l.cfg)
+}
+
+// LoadDocument loads the config with the registered modules, see LoadDocument
+func (l *Loader) LoadDocument() (*Document, error) {
+	return LoadDocument(l.cfg)
+}
+
+// LoadCascade loads the cascading configs with the registered modules, see LoadCascade
+func (l *Loader) LoadCascade(opts CascadeOptions) (map[string]any, []string, error) {
+	return LoadCascade(l.cfg, opts)
+}
+
+// BindToViper loads the config with the registered modules into v, see BindToViper
+func (l *Loader) BindToViper(v *viper.Viper) error {
+	return BindToViper(l.cfg, v)
+}
+
+// RegisterLuaCommands registers the commands of the config with the registered modules available
+// to the config and to the commands, see RegisterLuaCommands
+func (l *Loader) RegisterLuaCommands(parent *cobra.Command) error {
+	return RegisterLuaCommands(parent, l.cfg)
+}
+
+// preloadModules installs the registered modules into package.preload
+func preloadModules(L *lua.LState, modules map[string]module) error {
+	if len(modules) == 0 {
+		return nil
+	}
+
+	preload, ok := L.GetField(L.GetGlobal("package"), "preload").(*lua.LTable)
+	if !ok {
+		return fmt.Errorf("package.preload is not available")
+	}
+
+	for name, m := range modules {
+		table := L.NewTable()
+
+		for key, value := range m.fields {
+			lv, err := internal.GoToLuaNamed(L, name+"."+key, value)
+			if err != nil {
+				return fmt.Errorf("failed to convert module %s: %w", name, err)
+			}
+			table.RawSetString(key, lv)
+		}
+
+		for key, fn := range m.funcs {
+			if _, exists := m.fields[key]; exists {
+				return fmt.Errorf("module %s defines %s as both a function and a field", name, key)
+			}
+			lv, err := internal.GoToLuaNamed(L, name+"."+key, fn)
+			if err != nil {
+				return fmt.Errorf("failed to convert module %s: %w", name, err)
+			}
+			if lv.Type() != lua.LTFunction {
+				return fmt.Errorf("module %s: %s is not a function", name, key)
+			}
+			table.RawSetString(key, lv)
+		}
+
+		preload.RawSetString(name, L.NewFunction(func(L *lua.LState) int {
+			L.Push(table)
+			return 1
+		}))
+	}
+
+	return nil
+}
@@ -0,0 +1,194 @@
+package culebra
+
+import (
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// registerNetworkModule registers an example acme.network module with CIDR helpers
+func registerNetworkModule(loader *Loader) {
+	loader.RegisterModule("acme.network",
+		map[string]any{
+			"contains": func(prefix, addr string) (bool, error) {
+				p, err := netip.ParsePrefix(prefix)
+				if err != nil {
+					return false, err
+				}
+				a, err := netip.ParseAddr(addr)
+				if err != nil {
+					return false, err
+				}
+				return p.Contains(a), nil
+			},
+			"hosts": func(prefix string) (int, error) {
+				p, err := netip.ParsePrefix(prefix)
+				if err != nil {
+					return 0, err
+				}
+				return 1<<(p.Addr().BitLen()-p.Bits()) - 2, nil
+			},
+		},
+		map[string]any{
+			"default_mtu": 1500,
+		},
+	)
+}
+
+func TestLoaderRegisterModule(t *testing.T) {
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "test.lua")
+
+	configContent := `
+local network = require("acme.network")
+
+return {
+    mtu = network.default_mtu,
+    internal = network.contains("10.0.0.0/8", "10.1.2.3"),
+    external = network.contains("10.0.0.0/8", "192.168.1.1"),
+    hosts = network.hosts("10.0.0.0/24"),
+}
+`
+
+	if err := os.WriteFile(configFile, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	loader := NewLoader(Config{FilePath: configFile})
+	registerNetworkModule(loader)
+
+	result, err := loader.Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if result["mtu"] != float64(1500) {
+		t.Errorf("Expected mtu=1500, got %v", result["mtu"])
+	}
+	if result["internal"] != true || result["external"] != false {
+		t.Errorf("Expected contains results true/false, got %v/%v", result["internal"], result["external"])
+	}
+	if result["hosts"] != float64(254) {
+		t.Errorf("Expected hosts=254, got %v", result["hosts"])
+	}
+}
+
+func TestLoaderRegisterModuleErrors(t *testing.T) {
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "test.lua")
+
+	configContent := `
+local network = require("acme.network")
+return { bad = network.contains("not-a-cidr", "10.0.0.1") }
+`
+
+	if err := os.WriteFile(configFile, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	loader := NewLoader(Config{FilePath: configFile})
+	registerNetworkModule(loader)
+
+	_, err := loader.Load()
+	if err == nil || !strings.Contains(err.Error(), "acme.network.contains") {
+		t.Errorf("Expected Go error from module function, got %v", err)
+	}
+
+	invalid := NewLoader(Config{FilePath: configFile})
+	invalid.RegisterModule("acme.network", map[string]any{"contains": "not a function"}, nil)
+	if _, err := invalid.Load(); err == nil {
+		t.Error("Expected error for non-function module entry")
+	}
+}
+
+func TestLoaderEntryPoints(t *testing.T) {
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, ".culebra-loader-test.lua")
+
+	configContent := `
+local network = require("acme.network")
+mtu = network.default_mtu
+commands = {
+    check = { run = function() assert(network.contains("10.0.0.0/8", "10.0.0.1")) end },
+}
+`
+
+	if err := os.WriteFile(configFile, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	loader := NewLoader(Config{FilePath: configFile})
+	registerNetworkModule(loader)
+
+	ordered, err := loader.LoadOrdered()
+	if err != nil {
+		t.Fatalf("LoadOrdered failed: %v", err)
+	}
+	if mtu, _ := ordered.Get("mtu"); mtu != float64(1500) {
+		t.Errorf("Expected mtu from LoadOrdered, got %v", mtu)
+	}
+
+	doc, err := loader.LoadDocument()
+	if err != nil {
+		t.Fatalf("LoadDocument failed: %v", err)
+	}
+	if doc.Data["mtu"] != float64(1500) {
+		t.Errorf("Expected mtu from LoadDocument, got %v", doc.Data["mtu"])
+	}
+
+	data, _, err := loader.LoadCascade(CascadeOptions{FileName: filepath.Base(configFile), StartDir: tmpDir})
+	if err != nil {
+		t.Fatalf("LoadCascade failed: %v", err)
+	}
+	if data["mtu"] != float64(1500) {
+		t.Errorf("Expected mtu from LoadCascade, got %v", data["mtu"])
+	}
+
+	v := viper.New()
+	if err := loader.BindToViper(v); err != nil {
+		t.Fatalf("BindToViper failed: %v", err)
+	}
+	if v.GetInt("mtu") != 1500 {
+		t.Errorf("Expected mtu in viper, got %v", v.Get("mtu"))
+	}
+
+	root := &cobra.Command{Use: "myapp", SilenceUsage: true, SilenceErrors: true}
+	if err := loader.RegisterLuaCommands(root); err != nil {
+		t.Fatalf("RegisterLuaCommands failed: %v", err)
+	}
+	root.SetArgs([]string{"check"})
+	if err := root.Execute(); err != nil {
+		t.Errorf("Expected command to use the module, got %v", err)
+	}
+}
+
+func ExampleLoader_RegisterModule() {
+	dir, _ := os.MkdirTemp("", "culebra-example")
+	defer os.RemoveAll(dir)
+
+	configFile := filepath.Join(dir, "config.lua")
+	_ = os.WriteFile(configFile, []byte(`
+local network = require("acme.network")
+return {
+    mtu = network.default_mtu,
+    private = network.contains("10.0.0.0/8", "10.1.2.3"),
+}
+`), 0644)
+
+	loader := NewLoader(Config{FilePath: configFile})
+	registerNetworkModule(loader)
+
+	data, err := loader.Load()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(data["mtu"], data["private"])
+	// Output: 1500 true
+}
@@ -2,60 +2,304 @@ package culebra
 
 import (
 	"fmt"
+	"maps"
 	"os"
+	"slices"
 
 	"github.com/Fuabioo/culebra/internal"
 	lua "github.com/yuin/gopher-lua"
 )
 
+// FunctionPolicy controls how functions, userdata and threads found in the config are converted
+type FunctionPolicy = internal.FunctionPolicy
+
+const (
+	// FunctionsDrop omits functions, userdata and threads from the result (the default)
+	FunctionsDrop = internal.FunctionsDrop
+	// FunctionsError fails the load, naming the key path of the value
+	FunctionsError = internal.FunctionsError
+	// FunctionsKeep keeps their string form, such as "function: 0xc000123"
+	FunctionsKeep = internal.FunctionsKeep
+)
+
+// MixedTablePolicy controls how sparse arrays and tables mixing array items with other keys are
+// converted when ConvertArrays is set
+type MixedTablePolicy = internal.MixedTablePolicy
+
+const (
+	// MixedAsMap converts them to maps with string keys such as "1" (the default)
+	MixedAsMap = internal.MixedAsMap
+	// MixedError fails the load, naming the key path of the table
+	MixedError = internal.MixedError
+	// MixedSplit converts them to maps holding the integer keys as a slice under ArrayPartKey
+	MixedSplit = internal.MixedSplit
+	// MixedFillHoles converts sparse arrays to slices with nil in the holes, mixed tables still become maps
+	MixedFillHoles = internal.MixedFillHoles
+)
+
+// ArrayPartKey holds the array items of a table converted with MixedSplit
+const ArrayPartKey = internal.ArrayPartKey
+
+// MaxArrayHoles is the number of holes beyond its item count that a sparse table may have before
+// MixedSplit and MixedFillHoles convert it to a map
+const MaxArrayHoles = internal.MaxArrayHoles
+
+// DefaultMetatableDepth limits how many metatables ResolveMetatables follows on one key path
+const DefaultMetatableDepth = internal.DefaultMetatableDepth
+
+// DefaultPrivatePrefix marks keys that are private to the script and omitted from the result
+const DefaultPrivatePrefix = "_"
+
 type Config struct {
-	FilePath      string
-	Globals       map[string]any
-	ConvertArrays bool // Convert Lua arrays to Go slices instead of maps
+	FilePath       string
+	Globals        map[string]any
+	ConvertArrays  bool // Convert Lua arrays to Go slices instead of maps
+	IncludeGlobals bool // Include injected Globals in traditional-style results even when the script leaves them unchanged
+
+	Functions     FunctionPolicy // How functions, userdata and threads are converted, dropped by default
+	PrivatePrefix string         // Top-level keys starting with this prefix are omitted, DefaultPrivatePrefix when empty; nested keys are always kept
+	KeepPrivate   bool           // Keep keys starting with PrivatePrefix
+
+	MixedTables MixedTablePolicy // How sparse and mixed tables are converted when ConvertArrays is set
+	TypedKeys   bool             // Convert nested tables to map[any]any keeping number and boolean keys
+
+	ResolveMetatables bool // Include keys inherited through __index tables and listed by __pairs
+	MetatableDepth    int  // Metatables followed on one key path, DefaultMetatableDepth when zero
+
+	Schema    any           // Validated after loading, either a *Schema or a struct passed to SchemaOf
+	OnWarning func(Finding) // Receives the culebra.warn messages of a config whose checks pass
+
+	Strict      bool     // Raise an error when the script, or a module it requires, reads a global never assigned
+	StrictAllow []string // Global names, not dotted keys, readable while unset in strict mode besides the injected Globals
+
+	modules      map[string]module // Go-backed modules registered through a Loader
+	trackOrigins bool              // Instrument the sources to record where keys are assigned
+	cascade      []string          // Layered files loaded by the Cobra integration, outermost first
+	skipSchemas  bool              // Skip validation, for cascade layers that are validated once merged
+	declared     []*Schema         // Schemas declared with culebra.schema, recorded when the Cobra integration binds the config
+
+	// Recorded when the Cobra integration binds the config, so the config command prints what
+	// Viper holds without evaluating the config again
+	bound        any                 // Bound data, an *OrderedMap for a single file and the merged map for a cascade
+	boundOrigins []map[string]Origin // Key origins of each bound file, outermost first
 }
 
 func Load(cfg Config) (map[string]any, error) {
+	L, table, err := evaluate(cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer L.Close()
+
+	return cfg.convert(L, table)
+}
+
+// convert converts an evaluated config to Go values and validates it
+func (cfg Config) convert(L *lua.LState, table *lua.LTable) (map[string]any, error) {
+	result, err := internal.ConvertTableWithState(L, table, cfg.convertOptions())
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert lua config: %w", err)
+	}
+
+	if err := cfg.validate(L, table, result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// validate checks a loaded config against Config.Schema and the schema declared with culebra.schema,
+// annotating violations with the origins of the offending keys
+func (cfg Config) validate(L *lua.LState, table *lua.LTable, data map[string]any) error {
+	if cfg.skipSchemas {
+		return nil
+	}
+	schemas, err := cfg.schemas(declaredSchema(L))
+	if err != nil || len(schemas) == 0 {
+		return err
+	}
+
+	err = validateAll(data, schemas...)
+	if errs, ok := err.(ValidationErrors); ok {
+		locateErrors(errs, cfg.origins(L, table))
+	}
+	if err != nil {
+		return fmt.Errorf("invalid lua config: %w", err)
+	}
+	return nil
+}
+
+// origins returns where the keys of an evaluated config were assigned. Configs evaluated without
+// instrumentation, which only declare their schema with culebra.schema, fall back to the keys
+// assigned literally in the config file.
+func (cfg Config) origins(L *lua.LState, table *lua.LTable) map[string]Origin {
+	if record := evaluationOf(L); record.instrument {
+		return record.keyOrigins(L, table)
+	}
+	return fileOrigins(cfg.FilePath)
+}
+
+// fileOrigins returns the keys assigned literally in a config file, found without evaluating it
+func fileOrigins(file string) map[string]Origin {
+	source, err := os.Open(file)
+	if err != nil {
+		return nil
+	}
+	defer source.Close()
+
+	lines, err := internal.KeyLines(source, file)
+	if err != nil {
+		return nil
+	}
+	origins := make(map[string]Origin, len(lines))
+	for key, line := range lines {
+		origins[key] = Origin{Key: key, File: file, Line: line}
+	}
+	return origins
+}
+
+// schemas returns Config.Schema followed by the given schemas declared with culebra.schema
+func (cfg Config) schemas(declared ...*Schema) ([]*Schema, error) {
+	var schemas []*Schema
+	if cfg.Schema != nil {
+		schema, err := resolveSchema(cfg.Schema)
+		if err != nil {
+			return nil, fmt.Errorf("invalid config schema: %w", err)
+		}
+		schemas = append(schemas, schema)
+	}
+	for _, schema := range declared {
+		if schema != nil {
+			schemas = append(schemas, schema)
+		}
+	}
+	return schemas, nil
+}
+
+// convertOptions returns the options used to convert the evaluated config to Go values
+func (cfg Config) convertOptions() internal.Options {
+	opts := internal.Options{
+		ConvertArrays: cfg.ConvertArrays,
+		Functions:     cfg.Functions,
+		PrivatePrefix: cfg.PrivatePrefix,
+		MixedTables:   cfg.MixedTables,
+		TypedKeys:     cfg.TypedKeys,
+
+		ResolveMetatables: cfg.ResolveMetatables,
+		MetatableDepth:    cfg.MetatableDepth,
+	}
+	if opts.PrivatePrefix == "" {
+		opts.PrivatePrefix = DefaultPrivatePrefix
+	}
+	if cfg.KeepPrivate {
+		opts.PrivatePrefix = ""
+	}
+	return opts
+}
+
+// evaluate executes the config file in a new Lua state and returns the state together with
+// the table holding the configuration. The caller is responsible for closing the state.
+func evaluate(cfg Config) (*lua.LState, *lua.LTable, error) {
 	if cfg.FilePath == "" {
-		return nil, fmt.Errorf("config file path is required")
+		return nil, nil, fmt.Errorf("config file path is required")
 	}
 
 	if _, err := os.Stat(cfg.FilePath); os.IsNotExist(err) {
-		return nil, fmt.Errorf("config file not found: %s", cfg.FilePath)
+		return nil, nil, fmt.Errorf("config file not found: %s", cfg.FilePath)
 	}
 
 	L := lua.NewState()
-	defer L.Close()
 
+	internal.OpenReadOnly(L)
+	openModule(L)
+	// A config validated against Config.Schema is instrumented so violations can be located
+	// without evaluating it again
+	record := openEvaluation(L, cfg.trackOrigins || cfg.Schema != nil)
+
+	if err := preloadModules(L, cfg.modules); err != nil {
+		L.Close()
+		return nil, nil, err
+	}
+
+	frozen := make(map[string]lua.LValue)
 	for key, value := range cfg.Globals {
-		L.SetGlobal(key, internal.GoToLua(L, value))
+		lv, err := internal.GoToLuaNamed(L, key, value)
+		if err != nil {
+			L.Close()
+			return nil, nil, fmt.Errorf("failed to convert global %s: %w", key, err)
+		}
+		if _, ok := value.(internal.ReadOnly); ok {
+			frozen[key] = lv
+			continue
+		}
+		L.SetGlobal(key, lv)
+	}
+	allowed := append([]string{ModuleName}, cfg.StrictAllow...)
+	for key := range cfg.Globals {
+		allowed = append(allowed, key)
+	}
+	internal.ProtectGlobals(L, internal.GlobalOptions{Frozen: frozen, Strict: cfg.Strict, Allow: allowed})
+
+	// Snapshot the globals so traditional-style results only contain what the script defines
+	globalTable := L.Get(lua.GlobalsIndex).(*lua.LTable)
+	snapshot := make(map[lua.LValue]lua.LValue)
+	globalTable.ForEach(func(key, value lua.LValue) {
+		snapshot[key] = value
+	})
+	// Injected tables are copied so changes the script makes in place are part of the result
+	injected := make(map[lua.LValue]*lua.LTable)
+	for key := range cfg.Globals {
+		if table, ok := globalTable.RawGetString(key).(*lua.LTable); ok {
+			injected[lua.LString(key)] = internal.CopyTable(table)
+		}
+	}
+
+	chunk, err := record.load(L, cfg.FilePath)
+	if err == nil {
+		L.Push(chunk)
+		err = L.PCall(0, lua.MultRet, nil)
+	}
+	if err != nil {
+		L.Close()
+		return nil, nil, fmt.Errorf("failed to execute lua config: %w", err)
 	}
 
-	if err := L.DoFile(cfg.FilePath); err != nil {
-		return nil, fmt.Errorf("failed to execute lua config: %w", err)
+	if err := reportChecks(L, cfg); err != nil {
+		L.Close()
+		return nil, nil, err
 	}
 
 	// Check if the Lua script returned a table
 	if L.GetTop() > 0 {
 		returnValue := L.Get(-1)
 		if table, ok := returnValue.(*lua.LTable); ok {
-			result := make(map[string]any)
-			table.ForEach(func(key, value lua.LValue) {
-				result[key.String()] = internal.LuaToGoWithConfig(value, cfg.ConvertArrays)
-			})
-			return result, nil
+			return L, table, nil
 		}
 	}
 
 	// Fallback to global variables (traditional style)
-	result := make(map[string]any)
-	globalTable := L.Get(lua.GlobalsIndex).(*lua.LTable)
-	globalTable.ForEach(func(key, value lua.LValue) {
-		if keyStr := key.String(); keyStr != "_G" && !isBuiltinGlobal(keyStr) {
-			result[keyStr] = internal.LuaToGoWithConfig(value, cfg.ConvertArrays)
+	record.style = StyleGlobals
+	result := L.NewTable()
+	internal.ForEachOrdered(globalTable, func(key, value lua.LValue) {
+		previous, existed := snapshot[key]
+		if !existed || previous != value {
+			result.RawSet(key, value)
+			return
+		}
+		if copied, ok := injected[key]; ok && !internal.EqualTables(copied, value.(*lua.LTable)) {
+			result.RawSet(key, value)
 		}
 	})
 
-	return result, nil
+	if cfg.IncludeGlobals {
+		for _, key := range slices.Sorted(maps.Keys(cfg.Globals)) {
+			if _, isFrozen := frozen[key]; !isFrozen {
+				result.RawSetString(key, globalTable.RawGetString(key))
+			}
+		}
+	}
+
+	return L, result, nil
 }
 
 // LoadWithArrays loads a Lua config file and converts arrays to Go slices
@@ -72,19 +316,3 @@ func LoadWithGlobals(filePath string, globals map[string]any) (map[string]any, e
 func LoadWithArraysAndGlobals(filePath string, globals map[string]any) (map[string]any, error) {
 	return Load(Config{FilePath: filePath, Globals: globals, ConvertArrays: true})
 }
-
-func isBuiltinGlobal(key string) bool {
-	builtins := map[string]bool{
-		"_VERSION": true, "assert": true, "collectgarbage": true, "dofile": true,
-		"error": true, "getfenv": true, "getmetatable": true, "ipairs": true,
-		"load": true, "loadfile": true, "loadstring": true, "next": true,
-		"pairs": true, "pcall": true, "print": true, "rawequal": true,
-		"rawget": true, "rawset": true, "require": true, "select": true,
-		"setfenv": true, "setmetatable": true, "tonumber": true, "tostring": true,
-		"type": true, "unpack": true, "xpcall": true, "coroutine": true,
-		"debug": true, "io": true, "math": true, "os": true, "package": true,
-		"string": true, "table": true, "_GOPHER_LUA_VERSION": true,
-		"_printregs": true, "channel": true, "module": true, "newproxy": true,
-	}
-	return builtins[key]
-}
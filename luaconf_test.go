@@ -1,8 +1,11 @@
 package culebra
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -138,3 +141,351 @@ return config
 		t.Errorf("Expected app.name='Returned App', got %v", app["name"])
 	}
 }
+
+func TestLoadWithTypedGlobals(t *testing.T) {
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "test.lua")
+
+	configContent := `
+total = ports[1] + ports[2] + limits.max
+`
+
+	if err := os.WriteFile(configFile, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	cfg := Config{
+		FilePath: configFile,
+		Globals: map[string]any{
+			"ports":  []int32{8080, 8081},
+			"limits": map[string]uint{"max": 10},
+		},
+	}
+
+	result, err := Load(cfg)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if result["total"] != float64(16171) {
+		t.Errorf("Expected total=16171, got %v", result["total"])
+	}
+}
+
+func TestLoadWithUnsupportedGlobal(t *testing.T) {
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "test.lua")
+
+	if err := os.WriteFile(configFile, []byte(`debug = true`), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	cfg := Config{
+		FilePath: configFile,
+		Globals:  map[string]any{"events": make(chan int)},
+	}
+
+	if _, err := Load(cfg); err == nil {
+		t.Error("Expected error for unsupported global type")
+	}
+}
+
+func TestLoadWithFunctionGlobals(t *testing.T) {
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "test.lua")
+
+	configContent := `
+return {
+    password = secret("db/password"),
+}
+`
+
+	if err := os.WriteFile(configFile, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	cfg := Config{
+		FilePath: configFile,
+		Globals: map[string]any{
+			"secret": func(path string) (string, error) {
+				if path != "db/password" {
+					return "", fmt.Errorf("unknown secret %s", path)
+				}
+				return "hunter2", nil
+			},
+		},
+	}
+
+	result, err := Load(cfg)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if result["password"] != "hunter2" {
+		t.Errorf("Expected password from Go function, got %v", result["password"])
+	}
+}
+
+func TestLoadTraditionalStyleGlobals(t *testing.T) {
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "test.lua")
+
+	configContent := `
+debug = true
+table = { name = "users" }
+port = base_port + 1
+string.shout = function(s) return s:upper() end
+`
+
+	if err := os.WriteFile(configFile, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	globals := map[string]any{"base_port": 8080}
+
+	result, err := Load(Config{FilePath: configFile, Globals: globals})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if result["debug"] != true {
+		t.Errorf("Expected user global named debug to be kept, got %v", result["debug"])
+	}
+	if users, ok := result["table"].(map[string]any); !ok || users["name"] != "users" {
+		t.Errorf("Expected user global named table to be kept, got %v", result["table"])
+	}
+	if result["port"] != float64(8081) {
+		t.Errorf("Expected port=8081, got %v", result["port"])
+	}
+	for _, key := range []string{"base_port", "string", "print", "_G"} {
+		if _, ok := result[key]; ok {
+			t.Errorf("Expected %s to be excluded, got %v", key, result[key])
+		}
+	}
+
+	result, err = Load(Config{FilePath: configFile, Globals: globals, IncludeGlobals: true})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if result["base_port"] != float64(8080) {
+		t.Errorf("Expected injected global with IncludeGlobals, got %v", result["base_port"])
+	}
+}
+
+func TestLoadTraditionalStyleMutatedGlobals(t *testing.T) {
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "test.lua")
+
+	configContent := `
+defaults.port = 9090
+limits.max = limits.max
+`
+
+	if err := os.WriteFile(configFile, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	globals := map[string]any{
+		"defaults": map[string]any{"host": "localhost", "port": 8080},
+		"limits":   map[string]any{"max": 10},
+	}
+
+	result, err := Load(Config{FilePath: configFile, Globals: globals})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	expected := map[string]any{
+		"defaults": map[string]any{"host": "localhost", "port": float64(9090)},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Expected injected table changed in place to be kept, got %v", result)
+	}
+}
+
+func TestLoadHelpersAndPrivateKeys(t *testing.T) {
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "test.lua")
+
+	configContent := `
+_base_url = "https://example.com"
+
+function endpoint(path)
+    return _base_url .. path
+end
+
+api = {
+    users = endpoint("/users"),
+    _retries = 3,
+}
+
+servers = {{ _id = 1 }}
+`
+
+	if err := os.WriteFile(configFile, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	result, err := Load(Config{FilePath: configFile})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	expected := map[string]any{
+		"api":     map[string]any{"users": "https://example.com/users", "_retries": float64(3)},
+		"servers": map[string]any{"1": map[string]any{"_id": float64(1)}},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Expected %v, got %v", expected, result)
+	}
+
+	result, err = Load(Config{FilePath: configFile, KeepPrivate: true, Functions: FunctionsKeep})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if result["_base_url"] != "https://example.com" {
+		t.Errorf("Expected private key with KeepPrivate, got %v", result["_base_url"])
+	}
+	if endpoint, ok := result["endpoint"].(string); !ok || !strings.HasPrefix(endpoint, "function:") {
+		t.Errorf("Expected function string with FunctionsKeep, got %v", result["endpoint"])
+	}
+
+	if _, err := Load(Config{FilePath: configFile, Functions: FunctionsError}); err == nil {
+		t.Error("Expected error with FunctionsError")
+	}
+}
+
+func TestLoadMixedTables(t *testing.T) {
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "test.lua")
+
+	configContent := `
+return {
+    servers = { "a", "b", nil, "d" },
+}
+`
+
+	if err := os.WriteFile(configFile, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	result, err := Load(Config{FilePath: configFile, ConvertArrays: true, MixedTables: MixedFillHoles})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	expected := []any{"a", "b", nil, "d"}
+	if !reflect.DeepEqual(result["servers"], expected) {
+		t.Errorf("Expected %v, got %v", expected, result["servers"])
+	}
+
+	_, err = Load(Config{FilePath: configFile, ConvertArrays: true, MixedTables: MixedError})
+	if err == nil || !strings.Contains(err.Error(), "servers") {
+		t.Errorf("Expected error naming the key path, got %v", err)
+	}
+}
+
+func TestLoadResolveMetatables(t *testing.T) {
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "test.lua")
+
+	configContent := `
+local defaults = { port = 8080, host = "localhost" }
+return setmetatable({ host = "example.com" }, { __index = defaults })
+`
+
+	if err := os.WriteFile(configFile, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	result, err := Load(Config{FilePath: configFile, ResolveMetatables: true})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	expected := map[string]any{"host": "example.com", "port": float64(8080)}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Expected %v, got %v", expected, result)
+	}
+}
+
+func TestLoadStrict(t *testing.T) {
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "test.lua")
+
+	configContent := `database = { host = "localhost" }
+databse.port = 5432
+`
+
+	if err := os.WriteFile(configFile, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	_, err := Load(Config{FilePath: configFile, Strict: true})
+	if err == nil {
+		t.Fatal("Expected error reading an undefined global")
+	}
+	if !strings.Contains(err.Error(), configFile+":2:") || !strings.Contains(err.Error(), "undefined global 'databse'") {
+		t.Errorf("Expected error with file, line and name, got %v", err)
+	}
+
+	if _, err := Load(Config{FilePath: configFile}); err == nil {
+		t.Error("Expected non-strict load to fail indexing nil")
+	}
+
+	allowed := filepath.Join(tmpDir, "allowed.lua")
+	allowedContent := `port = override_port or 8080
+region = env.region
+`
+	if err := os.WriteFile(allowed, []byte(allowedContent), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	result, err := Load(Config{
+		FilePath:    allowed,
+		Globals:     map[string]any{"env": Frozen(map[string]any{"region": "eu"})},
+		Strict:      true,
+		StrictAllow: []string{"override_port"},
+	})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if result["port"] != float64(8080) || result["region"] != "eu" {
+		t.Errorf("Expected allowed and injected globals to be readable, got %v", result)
+	}
+}
+
+func TestLoadDocumentStrict(t *testing.T) {
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "test.lua")
+	moduleFile := filepath.Join(tmpDir, "defaults.lua")
+
+	if err := os.WriteFile(moduleFile, []byte("return { retries = retry_count }\n"), 0644); err != nil {
+		t.Fatalf("Failed to write module: %v", err)
+	}
+	configContent := `package.path = "` + filepath.Join(tmpDir, "?.lua") + `"
+rawset(_G, "raw", 1)
+name, count = "app", raw
+port = override_port or 8080
+defaults = require("defaults")
+`
+	if err := os.WriteFile(configFile, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	cfg := Config{FilePath: configFile, Strict: true, StrictAllow: []string{"override_port"}}
+	_, err := LoadDocument(cfg)
+	if err == nil || !strings.Contains(err.Error(), moduleFile+":1:") || !strings.Contains(err.Error(), "undefined global 'retry_count'") {
+		t.Fatalf("Expected required module to read the strict globals, got %v", err)
+	}
+
+	cfg.StrictAllow = append(cfg.StrictAllow, "retry_count")
+	doc, err := LoadDocument(cfg)
+	if err != nil {
+		t.Fatalf("LoadDocument failed: %v", err)
+	}
+	if doc.Data["name"] != "app" || doc.Data["count"] != float64(1) || doc.Data["port"] != float64(8080) {
+		t.Errorf("Expected globals assigned through hooks and rawset to be readable, got %v", doc.Data)
+	}
+	if origin, ok := doc.Origin("count"); !ok || origin.Line != 3 {
+		t.Errorf("Expected count to be assigned at line 3, got %v", origin)
+	}
+}
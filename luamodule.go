@@ -0,0 +1,22 @@
+package culebra
+
+import (
+	"github.com/Fuabioo/culebra/internal"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// ModuleName is the name of the Lua module with culebra helpers, available to every config
+// as a global and through require
+const ModuleName = "culebra"
+
+// openModule installs the culebra module as a global and in package.loaded
+func openModule(L *lua.LState) *lua.LTable {
+	module := L.NewTable()
+	internal.OpenSentinels(L, module)
+	openSchema(L, module)
+	openChecks(L, module)
+
+	L.SetGlobal(ModuleName, module)
+	L.SetField(L.GetField(L.GetGlobal("package"), "loaded"), ModuleName, module)
+	return module
+}
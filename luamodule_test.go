@@ -0,0 +1,64 @@
+package culebra
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestCulebraModuleSentinels(t *testing.T) {
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "test.lua")
+
+	configContent := `
+local culebra = require("culebra")
+
+return {
+    database = {
+        password = culebra.null,
+        replicas = culebra.array{},
+        options = culebra.object{},
+    },
+}
+`
+
+	if err := os.WriteFile(configFile, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	v := viper.New()
+	if err := BindToViper(Config{FilePath: configFile}, v); err != nil {
+		t.Fatalf("BindToViper failed: %v", err)
+	}
+
+	var config struct {
+		Database struct {
+			Password *string  `mapstructure:"password"`
+			Replicas []string `mapstructure:"replicas"`
+		} `mapstructure:"database"`
+	}
+	if err := v.Unmarshal(&config); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	if config.Database.Password != nil {
+		t.Errorf("Expected nil password, got %v", *config.Database.Password)
+	}
+	if config.Database.Replicas == nil || len(config.Database.Replicas) != 0 {
+		t.Errorf("Expected empty replicas slice, got %#v", config.Database.Replicas)
+	}
+
+	data, err := Load(Config{FilePath: configFile})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	database := data["database"].(map[string]any)
+	if options, ok := database["options"].(map[string]any); !ok || len(options) != 0 {
+		t.Errorf("Expected empty options map, got %#v", database["options"])
+	}
+	if password, ok := database["password"]; !ok || password != nil {
+		t.Errorf("Expected explicit nil password, got %#v", password)
+	}
+}
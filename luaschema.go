@@ -0,0 +1,215 @@
+package culebra
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/Fuabioo/culebra/internal"
+	lua "github.com/yuin/gopher-lua"
+)
+
+const (
+	// schemaTypeMetatable is the registry name of the metatable shared by schema type userdata
+	schemaTypeMetatable = "culebra.schema"
+	// declaredSchemaKey is the registry key holding the schema declared with culebra.schema
+	declaredSchemaKey = "culebra.schema.declared"
+)
+
+// schemaTypeNames are the culebra module fields holding the scalar schema types
+var schemaTypeNames = map[string]string{
+	"int":    TypeInteger,
+	"number": TypeNumber,
+	"string": TypeString,
+	"bool":   TypeBoolean,
+	"any":    TypeAny,
+}
+
+// openSchema adds the schema DSL to the culebra module. culebra.int, number, string, bool and any
+// are types usable as-is or called with options, culebra.list and culebra.map build container
+// types, and culebra.schema declares the shape the config must have:
+//
+//	culebra.schema{
+//	    port = culebra.int{ min = 1, max = 65535, required = true },
+//	    level = culebra.string{ enum = { "debug", "info" } },
+//	    hosts = culebra.list(culebra.string, { min = 1 }),
+//	    server = { name = culebra.string },
+//	}
+func openSchema(L *lua.LState, module *lua.LTable) {
+	meta := L.NewTypeMetatable(schemaTypeMetatable)
+	meta.RawSetString("__call", L.NewFunction(callSchemaType))
+	meta.RawSetString("__tostring", L.NewFunction(func(L *lua.LState) int {
+		L.Push(lua.LString("culebra." + schemaTypeName(checkSchemaType(L, 1))))
+		return 1
+	}))
+	meta.RawSetString("__metatable", lua.LString(schemaTypeMetatable))
+
+	for name, schemaType := range schemaTypeNames {
+		module.RawSetString(name, newSchemaType(L, &Schema{Type: schemaType}))
+	}
+
+	module.RawSetString("list", L.NewFunction(func(L *lua.LState) int {
+		return pushContainerType(L, TypeArray)
+	}))
+	module.RawSetString("map", L.NewFunction(func(L *lua.LState) int {
+		return pushContainerType(L, TypeObject)
+	}))
+	module.RawSetString("schema", L.NewFunction(func(L *lua.LState) int {
+		schema, err := schemaFromLua(L.CheckTable(1), "")
+		if err != nil {
+			L.ArgError(1, err.Error())
+		}
+		L.SetField(L.Get(lua.RegistryIndex), declaredSchemaKey, newSchemaType(L, schema))
+		return 0
+	}))
+}
+
+// declaredSchema returns the schema declared with culebra.schema, or nil
+func declaredSchema(L *lua.LState) *Schema {
+	ud, ok := L.GetField(L.Get(lua.RegistryIndex), declaredSchemaKey).(*lua.LUserData)
+	if !ok {
+		return nil
+	}
+	schema, _ := ud.Value.(*Schema)
+	return schema
+}
+
+// newSchemaType wraps a schema in userdata with the schema type metatable
+func newSchemaType(L *lua.LState, schema *Schema) *lua.LUserData {
+	ud := L.NewUserData()
+	ud.Value = schema
+	ud.Metatable = L.GetTypeMetatable(schemaTypeMetatable)
+	return ud
+}
+
+// checkSchemaType returns the schema held by the argument at n or raises an argument error
+func checkSchemaType(L *lua.LState, n int) *Schema {
+	if ud, ok := L.Get(n).(*lua.LUserData); ok {
+		if schema, ok := ud.Value.(*Schema); ok {
+			return schema
+		}
+	}
+	L.ArgError(n, "culebra schema type expected")
+	return nil
+}
+
+// callSchemaType implements type{options}, returning a copy of the type with the options applied
+func callSchemaType(L *lua.LState) int {
+	schema := *checkSchemaType(L, 1)
+	if err := applyLuaSchemaOptions(&schema, L.OptTable(2, L.NewTable())); err != nil {
+		L.ArgError(2, err.Error())
+	}
+	L.Push(newSchemaType(L, &schema))
+	return 1
+}
+
+// pushContainerType implements culebra.list(items, options) and culebra.map(values, options)
+func pushContainerType(L *lua.LState, schemaType string) int {
+	items, err := schemaFromLua(L.CheckAny(1), "")
+	if err != nil {
+		L.ArgError(1, err.Error())
+	}
+	schema := &Schema{Type: schemaType, Items: items}
+	if err := applyLuaSchemaOptions(schema, L.OptTable(2, L.NewTable())); err != nil {
+		L.ArgError(2, err.Error())
+	}
+	L.Push(newSchemaType(L, schema))
+	return 1
+}
+
+// schemaFromLua converts a schema type, or a table of them describing an object, to a Schema
+func schemaFromLua(lv lua.LValue, path string) (*Schema, error) {
+	switch v := lv.(type) {
+	case *lua.LUserData:
+		if schema, ok := v.Value.(*Schema); ok {
+			return schema, nil
+		}
+	case *lua.LTable:
+		schema := &Schema{Type: TypeObject, Fields: make(map[string]*Schema)}
+		var err error
+		v.ForEach(func(key, value lua.LValue) {
+			if err != nil {
+				return
+			}
+			name, ok := key.(lua.LString)
+			if !ok {
+				err = fmt.Errorf("schema keys must be strings, got %s at %s", key.Type(), displaySchemaPath(path))
+				return
+			}
+			schema.Fields[string(name)], err = schemaFromLua(value, joinSchemaPath(path, string(name)))
+		})
+		if err != nil {
+			return nil, err
+		}
+		return schema, nil
+	}
+	return nil, fmt.Errorf("culebra schema type or table expected at %s, got %s", displaySchemaPath(path), lv.Type())
+}
+
+// applyLuaSchemaOptions applies the required, min, max, pattern and enum fields of an options table
+func applyLuaSchemaOptions(schema *Schema, options *lua.LTable) error {
+	var err error
+	options.ForEach(func(key, value lua.LValue) {
+		if err != nil {
+			return
+		}
+		switch key.String() {
+		case "required":
+			schema.Required = lua.LVAsBool(value)
+		case "min", "max":
+			number, ok := value.(lua.LNumber)
+			if !ok {
+				err = fmt.Errorf("%s must be a number, got %s", key, value.Type())
+				return
+			}
+			bound := float64(number)
+			if key.String() == "min" {
+				schema.Min = &bound
+			} else {
+				schema.Max = &bound
+			}
+		case "pattern":
+			pattern, ok := value.(lua.LString)
+			if !ok {
+				err = fmt.Errorf("pattern must be a string, got %s", value.Type())
+				return
+			}
+			if _, compileErr := regexp.Compile(string(pattern)); compileErr != nil {
+				err = fmt.Errorf("invalid pattern %q: %w", pattern, compileErr)
+				return
+			}
+			schema.Pattern = string(pattern)
+		case "enum":
+			members, ok := value.(*lua.LTable)
+			if !ok {
+				err = fmt.Errorf("enum must be a list, got %s", value.Type())
+				return
+			}
+			schema.Enum = nil
+			for i := 1; i <= members.Len(); i++ {
+				schema.Enum = append(schema.Enum, internal.LuaToGo(members.RawGetInt(i)))
+			}
+		default:
+			err = fmt.Errorf("unknown schema option %q", key.String())
+		}
+	})
+	return err
+}
+
+// schemaTypeName returns the culebra module name of a schema type
+func schemaTypeName(schema *Schema) string {
+	switch schema.Type {
+	case TypeArray:
+		return "list"
+	case TypeObject:
+		if len(schema.Fields) > 0 {
+			return "schema"
+		}
+		return "map"
+	}
+	for name, schemaType := range schemaTypeNames {
+		if schemaType == schema.Type && name != "any" {
+			return name
+		}
+	}
+	return "any"
+}
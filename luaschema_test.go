@@ -0,0 +1,88 @@
+package culebra
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLuaSchema(t *testing.T) {
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "test.lua")
+
+	configContent := `culebra.schema{
+    port = culebra.int{ min = 1, max = 65535, required = true },
+    level = culebra.string{ enum = { "debug", "info" } },
+    hosts = culebra.list(culebra.string, { min = 1 }),
+    server = { name = culebra.string{ required = true } },
+}
+
+return {
+    port = 0,
+    level = "trace",
+    hosts = { "a", 2 },
+    server = {},
+}
+`
+
+	if err := os.WriteFile(configFile, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	_, err := Load(Config{FilePath: configFile, ConvertArrays: true})
+	var errs ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("Expected ValidationErrors, got %v", err)
+	}
+
+	var paths []string
+	for _, e := range errs {
+		paths = append(paths, e.Path)
+	}
+	expected := []string{"hosts.2", "level", "port", "server.name"}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Errorf("Expected violations at %v, got %v", expected, paths)
+	}
+	if errs[2].Line != 9 {
+		t.Errorf("Expected port violation at line 9, got %+v", errs[2])
+	}
+}
+
+func TestLuaSchemaValid(t *testing.T) {
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "test.lua")
+
+	configContent := `
+culebra.schema{ limits = culebra.map(culebra.number{ max = 10 }) }
+limits = { cpu = 2, memory = 8 }
+`
+
+	if err := os.WriteFile(configFile, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	result, err := Load(Config{FilePath: configFile})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if limits := result["limits"].(map[string]any); len(limits) != 2 {
+		t.Errorf("Expected limits, got %v", limits)
+	}
+}
+
+func TestLuaSchemaInvalidDeclaration(t *testing.T) {
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "test.lua")
+
+	if err := os.WriteFile(configFile, []byte(`culebra.schema{ port = 80 }`), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	_, err := Load(Config{FilePath: configFile})
+	if err == nil || !strings.Contains(err.Error(), "port") {
+		t.Errorf("Expected error naming the invalid schema key, got %v", err)
+	}
+}
@@ -0,0 +1,32 @@
+package culebra
+
+import (
+	"fmt"
+
+	"github.com/Fuabioo/culebra/internal"
+)
+
+// OrderedMap is a config map that keeps keys in the order they were first assigned in Lua,
+// so dumps and diffs of the config are stable. It encodes to JSON in that order.
+type OrderedMap = internal.OrderedMap
+
+// LoadOrdered loads a Lua config like Load but returns ordered maps for the config and every
+// nested table. The TypedKeys option is ignored.
+func LoadOrdered(cfg Config) (*OrderedMap, error) {
+	L, table, err := evaluate(cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer L.Close()
+
+	result, err := internal.ConvertOrderedTable(L, table, cfg.convertOptions())
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert lua config: %w", err)
+	}
+
+	if err := cfg.validate(L, table, result.ToMap()); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
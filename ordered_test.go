@@ -0,0 +1,36 @@
+package culebra
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadOrdered(t *testing.T) {
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "test.lua")
+
+	configContent := `
+name = "app"
+server = { port = 8080, host = "localhost" }
+verbose = false
+`
+
+	if err := os.WriteFile(configFile, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	result, err := LoadOrdered(Config{FilePath: configFile})
+	if err != nil {
+		t.Fatalf("LoadOrdered failed: %v", err)
+	}
+	if keys := result.Keys(); !reflect.DeepEqual(keys, []string{"name", "server", "verbose"}) {
+		t.Errorf("Expected globals in assignment order, got %v", keys)
+	}
+
+	server, _ := result.Get("server")
+	if keys := server.(*OrderedMap).Keys(); !reflect.DeepEqual(keys, []string{"port", "host"}) {
+		t.Errorf("Expected nested keys in constructor order, got %v", keys)
+	}
+}
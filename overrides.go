@@ -0,0 +1,83 @@
+package culebra
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseOverrides parses "a.b.c=value" assignments into nested maps suitable for Config.Globals.
+// Values are typed: true/false become bools, numeric literals become numbers and quoted
+// values are always kept as strings.
+func ParseOverrides(assignments []string) (map[string]any, error) {
+	result := make(map[string]any)
+
+	for _, assignment := range assignments {
+		key, raw, found := strings.Cut(assignment, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid override %q: expected key=value", assignment)
+		}
+
+		key = strings.TrimSpace(key)
+		if key == "" {
+			return nil, fmt.Errorf("invalid override %q: empty key", assignment)
+		}
+
+		parts := strings.Split(key, ".")
+		current := result
+		for i, part := range parts {
+			if part == "" {
+				return nil, fmt.Errorf("invalid override %q: empty key segment", assignment)
+			}
+
+			if i == len(parts)-1 {
+				if _, isMap := current[part].(map[string]any); isMap {
+					return nil, fmt.Errorf("override %q conflicts with nested overrides of %q", assignment, key)
+				}
+				current[part] = parseOverrideValue(raw)
+				break
+			}
+
+			next, exists := current[part]
+			if !exists {
+				nested := make(map[string]any)
+				current[part] = nested
+				current = nested
+				continue
+			}
+
+			nested, ok := next.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("override %q conflicts with the value already set for %q", assignment, strings.Join(parts[:i+1], "."))
+			}
+			current = nested
+		}
+	}
+
+	return result, nil
+}
+
+// parseOverrideValue converts the raw text of an override into a typed Go value
+func parseOverrideValue(raw string) any {
+	if len(raw) >= 2 {
+		if (raw[0] == '"' && raw[len(raw)-1] == '"') || (raw[0] == '\'' && raw[len(raw)-1] == '\'') {
+			return raw[1 : len(raw)-1]
+		}
+	}
+
+	switch raw {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+
+	if i, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		return i
+	}
+	if f, err := strconv.ParseFloat(raw, 64); err == nil && strings.ContainsAny(raw, "0123456789") {
+		return f
+	}
+
+	return raw
+}
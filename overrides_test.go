@@ -0,0 +1,125 @@
+package culebra
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func TestParseOverrides(t *testing.T) {
+	result, err := ParseOverrides([]string{
+		"database.host=db.internal",
+		"database.port=5433",
+		"database.ssl=true",
+		"ratio=0.5",
+		"name=\"8080\"",
+		"empty=",
+	})
+	if err != nil {
+		t.Fatalf("ParseOverrides failed: %v", err)
+	}
+
+	expected := map[string]any{
+		"database": map[string]any{
+			"host": "db.internal",
+			"port": int64(5433),
+			"ssl":  true,
+		},
+		"ratio": 0.5,
+		"name":  "8080",
+		"empty": "",
+	}
+
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("expected %v, got %v", expected, result)
+	}
+}
+
+func TestParseOverridesInvalid(t *testing.T) {
+	tests := []struct {
+		name        string
+		assignments []string
+	}{
+		{"missing equals", []string{"database.host"}},
+		{"empty key", []string{"=value"}},
+		{"empty segment", []string{"database..host=x"}},
+		{"scalar then nested", []string{"database=x", "database.host=y"}},
+		{"nested then scalar", []string{"database.host=y", "database=x"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseOverrides(tt.assignments); err == nil {
+				t.Errorf("expected error for %v", tt.assignments)
+			}
+		})
+	}
+}
+
+func TestOverridesVisibleToScript(t *testing.T) {
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "test.lua")
+
+	configContent := `
+local db = database or {}
+return {
+    database = {
+        host = db.host or "localhost",
+        url = "postgres://" .. (db.host or "localhost") .. ":" .. (db.port or 5432)
+    }
+}
+`
+
+	if err := os.WriteFile(configFile, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	globals, err := ParseOverrides([]string{"database.host=db.internal", "database.port=6543"})
+	if err != nil {
+		t.Fatalf("ParseOverrides failed: %v", err)
+	}
+
+	result, err := Load(Config{FilePath: configFile, Globals: globals})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	database, ok := result["database"].(map[string]any)
+	if !ok {
+		t.Fatalf("Expected database to be a map, got %T", result["database"])
+	}
+
+	if database["url"] != "postgres://db.internal:6543" {
+		t.Errorf("Expected derived url, got %v", database["url"])
+	}
+}
+
+func TestOverridesWithoutLuaConfig(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(configFile, []byte("database:\n  host: localhost\n  port: 5432\n"), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+	defer viper.Reset()
+
+	values, err := ParseOverrides([]string{"database.port=6543", "debug=true"})
+	if err != nil {
+		t.Fatalf("ParseOverrides failed: %v", err)
+	}
+
+	cmd := &cobra.Command{Use: "myapp"}
+	if loadCobraConfig(cmd, CobraOptions{}, configFile, values) {
+		t.Fatal("Expected a YAML config not to be reported as a Lua config")
+	}
+	setOverrides(viper.GetViper(), values, "")
+
+	if viper.GetInt("database.port") != 6543 || !viper.GetBool("debug") {
+		t.Errorf("Expected overrides to be set in Viper, got %v", viper.AllSettings())
+	}
+	if viper.GetString("database.host") != "localhost" {
+		t.Errorf("Expected overrides to keep the rest of their section, got %v", viper.AllSettings())
+	}
+}
@@ -0,0 +1,620 @@
+package culebra
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Fuabioo/culebra/internal"
+)
+
+// Schema types, named after their JSON Schema counterparts
+const (
+	TypeAny     = ""
+	TypeString  = "string"
+	TypeNumber  = "number"
+	TypeInteger = "integer"
+	TypeBoolean = "boolean"
+	TypeArray   = "array"
+	TypeObject  = "object"
+)
+
+// SchemaTag is the struct tag read by SchemaOf, e.g. `culebra:"port,required,min=1,max=65535"`
+// or `culebra:"level,enum=debug|info|warn"`
+const SchemaTag = "culebra"
+
+// Schema describes the expected shape of a config value
+type Schema struct {
+	Type     string             // One of the Type constants, TypeAny accepts every value
+	Required bool               // The key must be present in its parent object
+	Min      *float64           // Lower bound of a number, or of the length of a string or array
+	Max      *float64           // Upper bound of a number, or of the length of a string or array
+	Enum     []any              // Allowed values
+	Fields   map[string]*Schema // Known keys of an object
+	Items    *Schema            // Items of an array, or values of the object keys not listed in Fields
+	Pattern  string             // Regular expression a string must match
+	Closed   bool               // Reject object keys not listed in Fields
+
+	// keywordType is the type of the values the constraints of a JSON Schema without a type
+	// apply to, values of other types are accepted
+	keywordType string
+}
+
+// ValidationError is a single violation of a schema
+type ValidationError struct {
+	Path    string // Dotted key path of the offending value
+	Message string
+	File    string // Lua file that set the value, when known
+	Line    int    // Line of the assignment in File, when known
+}
+
+func (e ValidationError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("%s: %s (%s:%d)", e.Path, e.Message, e.File, e.Line)
+	}
+	return fmt.Sprintf("%s: %s", e.Path, e.Message)
+}
+
+// ValidationErrors holds every violation found by Validate, sorted by path
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%d config errors:", len(e))
+	for _, err := range e {
+		buf.WriteString("\n  " + err.Error())
+	}
+	return buf.String()
+}
+
+// SchemaOf derives a schema from a struct, or a pointer to one, using the culebra tag for
+// constraints and the lua, mapstructure or json tags for key names
+func SchemaOf(v any) (*Schema, error) {
+	t := reflect.TypeOf(v)
+	if t == nil {
+		return nil, fmt.Errorf("schema source is nil")
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("schema source must be a struct, got %s", t)
+	}
+	return schemaDeriver{expanding: make(map[reflect.Type]bool)}.schemaOfType(t, "")
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// schemaDeriver derives schemas from Go types
+type schemaDeriver struct {
+	// expanding holds the struct types on the current path to detect recursive types
+	expanding map[reflect.Type]bool
+}
+
+// schemaOfType derives the schema of a Go type, path names the field in errors
+func (d schemaDeriver) schemaOfType(t reflect.Type, path string) (*Schema, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == durationType || t == reflect.TypeOf(time.Time{}) {
+		return &Schema{}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: TypeString}, nil
+	case reflect.Bool:
+		return &Schema{Type: TypeBoolean}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: TypeInteger}, nil
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: TypeNumber}, nil
+	case reflect.Slice, reflect.Array:
+		items, err := d.schemaOfType(t.Elem(), path)
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: TypeArray, Items: items}, nil
+	case reflect.Map:
+		values, err := d.schemaOfType(t.Elem(), path)
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: TypeObject, Items: values}, nil
+	case reflect.Struct:
+		schema := &Schema{Type: TypeObject, Fields: make(map[string]*Schema)}
+		if err := d.addStructFields(schema, t, path); err != nil {
+			return nil, err
+		}
+		return schema, nil
+	case reflect.Interface:
+		return &Schema{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported type %s for schema at %s", t, displaySchemaPath(path))
+	}
+}
+
+// addStructFields adds the exported fields of a struct to schema, flattening untagged embedded
+// structs. A struct containing itself has no finite schema and is reported as recursive.
+func (d schemaDeriver) addStructFields(schema *Schema, t reflect.Type, path string) error {
+	if d.expanding[t] {
+		return fmt.Errorf("recursive type %s at %s", t, displaySchemaPath(path))
+	}
+	d.expanding[t] = true
+	defer delete(d.expanding, t)
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, skip := internal.TagName(field)
+		var options []string
+		if tag, ok := field.Tag.Lookup(SchemaTag); ok {
+			parts := strings.Split(tag, ",")
+			if parts[0] == "-" && len(parts) == 1 {
+				continue
+			}
+			if parts[0] != "" {
+				name = parts[0]
+			}
+			options = parts[1:]
+		}
+		if skip {
+			continue
+		}
+
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				if err := d.addStructFields(schema, embedded, path); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		fieldPath := joinSchemaPath(path, name)
+		fieldSchema, err := d.schemaOfType(field.Type, fieldPath)
+		if err != nil {
+			return err
+		}
+		if err := applySchemaOptions(fieldSchema, options); err != nil {
+			return fmt.Errorf("invalid %s tag at %s: %w", SchemaTag, fieldPath, err)
+		}
+		schema.Fields[name] = fieldSchema
+	}
+	return nil
+}
+
+// applySchemaOptions applies the required, min, max, pattern and enum options of a culebra tag
+func applySchemaOptions(schema *Schema, options []string) error {
+	for _, option := range options {
+		key, value, _ := strings.Cut(option, "=")
+		switch key {
+		case "required":
+			schema.Required = true
+		case "min", "max":
+			bound, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return fmt.Errorf("%s must be a number, got %q", key, value)
+			}
+			if key == "min" {
+				schema.Min = &bound
+			} else {
+				schema.Max = &bound
+			}
+		case "pattern":
+			if _, err := regexp.Compile(value); err != nil {
+				return fmt.Errorf("invalid pattern %q: %w", value, err)
+			}
+			schema.Pattern = value
+		case "enum":
+			for _, member := range strings.Split(value, "|") {
+				enumValue, err := parseEnumValue(schema.Type, member)
+				if err != nil {
+					return err
+				}
+				schema.Enum = append(schema.Enum, enumValue)
+			}
+		case "":
+		default:
+			return fmt.Errorf("unknown option %q", key)
+		}
+	}
+	return nil
+}
+
+// parseEnumValue parses an enum member according to the type of the field
+func parseEnumValue(schemaType, member string) (any, error) {
+	switch schemaType {
+	case TypeNumber, TypeInteger:
+		number, err := strconv.ParseFloat(member, 64)
+		if err != nil {
+			return nil, fmt.Errorf("enum value %q must be a number", member)
+		}
+		return number, nil
+	case TypeBoolean:
+		value, err := strconv.ParseBool(member)
+		if err != nil {
+			return nil, fmt.Errorf("enum value %q must be a boolean", member)
+		}
+		return value, nil
+	default:
+		return member, nil
+	}
+}
+
+// Validate checks data against a schema, given as a *Schema or as a struct for SchemaOf, and
+// returns ValidationErrors listing every violation, or nil when the data is valid
+func Validate(data map[string]any, schema any) error {
+	resolved, err := resolveSchema(schema)
+	if err != nil {
+		return err
+	}
+	return validateAll(data, resolved)
+}
+
+// validateAll checks data against every schema and returns their violations sorted by path
+func validateAll(data map[string]any, schemas ...*Schema) error {
+	var errs ValidationErrors
+	for _, schema := range schemas {
+		schema.validate(data, "", &errs)
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	sort.SliceStable(errs, func(i, j int) bool { return errs[i].Path < errs[j].Path })
+	return errs
+}
+
+// resolveSchema accepts a *Schema or a struct to derive one from
+func resolveSchema(schema any) (*Schema, error) {
+	if s, ok := schema.(*Schema); ok {
+		return s, nil
+	}
+	return SchemaOf(schema)
+}
+
+// locateErrors annotates violations with the file and line that assigned the offending key,
+// or its nearest assigned parent
+func locateErrors(errs ValidationErrors, origins map[string]Origin) {
+	doc := &Document{Origins: origins}
+	for i := range errs {
+		if origin, found := doc.Origin(errs[i].Path); found {
+			errs[i].File, errs[i].Line = origin.File, origin.Line
+		}
+	}
+}
+
+// validate appends the violations of value and its nested values to errs
+func (s *Schema) validate(value any, path string, errs *ValidationErrors) {
+	fail := func(format string, args ...any) {
+		*errs = append(*errs, ValidationError{Path: displaySchemaPath(path), Message: fmt.Sprintf(format, args...)})
+	}
+
+	if value == nil {
+		return
+	}
+
+	schemaType := s.Type
+	if schemaType == TypeAny && hasType(value, s.keywordType) {
+		schemaType = s.keywordType
+	}
+
+	switch schemaType {
+	case TypeString:
+		text, ok := value.(string)
+		if !ok {
+			fail("must be a string, got %s", describeValue(value))
+			return
+		}
+		s.checkBounds(float64(utf8.RuneCountInString(text)), "length", fail)
+		if s.Pattern != "" {
+			if matched, err := regexp.MatchString(s.Pattern, text); err != nil {
+				fail("has invalid pattern %q: %v", s.Pattern, err)
+			} else if !matched {
+				fail("must match %q, got %q", s.Pattern, text)
+			}
+		}
+	case TypeNumber, TypeInteger:
+		number, ok := toNumber(value)
+		if !ok {
+			fail("must be a %s, got %s", schemaType, describeValue(value))
+			return
+		}
+		if schemaType == TypeInteger && number != math.Trunc(number) {
+			fail("must be an integer, got %v", number)
+			return
+		}
+		s.checkBounds(number, "", fail)
+	case TypeBoolean:
+		if _, ok := value.(bool); !ok {
+			fail("must be a boolean, got %s", describeValue(value))
+			return
+		}
+	case TypeArray:
+		items, ok := toArray(value)
+		if !ok {
+			fail("must be an array, got %s", describeValue(value))
+			return
+		}
+		s.checkBounds(float64(len(items)), "length", fail)
+		if s.Items != nil {
+			for i, item := range items {
+				s.Items.validate(item, joinSchemaPath(path, strconv.Itoa(i+1)), errs)
+			}
+		}
+	case TypeObject:
+		object, ok := toObject(value)
+		if !ok {
+			fail("must be an object, got %s", describeValue(value))
+			return
+		}
+		s.validateObject(object, path, errs)
+	}
+
+	if len(s.Enum) > 0 && !s.allows(value) {
+		fail("must be one of %s, got %v", formatEnum(s.Enum), value)
+	}
+}
+
+// hasType reports whether value is of the given schema type, as the constraints of that type
+// expect it
+func hasType(value any, schemaType string) bool {
+	switch schemaType {
+	case TypeString:
+		_, ok := value.(string)
+		return ok
+	case TypeNumber:
+		_, ok := toNumber(value)
+		return ok
+	case TypeArray:
+		_, ok := toArray(value)
+		return ok
+	case TypeObject:
+		_, ok := toObject(value)
+		return ok
+	default:
+		return false
+	}
+}
+
+// validateObject checks the declared fields of an object and, without fields, all of its values
+func (s *Schema) validateObject(object map[string]any, path string, errs *ValidationErrors) {
+	names := make([]string, 0, len(s.Fields))
+	for name := range s.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		field := s.Fields[name]
+		fieldPath := joinSchemaPath(path, name)
+		value, ok := lookupKey(object, name)
+		if !ok || value == nil {
+			if field.Required {
+				*errs = append(*errs, ValidationError{Path: fieldPath, Message: "is required"})
+			}
+			continue
+		}
+		field.validate(value, fieldPath, errs)
+	}
+
+	if s.Items == nil && !s.Closed {
+		return
+	}
+	for key, value := range object {
+		if s.declares(key) {
+			continue
+		}
+		keyPath := joinSchemaPath(path, key)
+		if s.Closed {
+			*errs = append(*errs, ValidationError{Path: keyPath, Message: "is not allowed"})
+			continue
+		}
+		s.Items.validate(value, keyPath, errs)
+	}
+}
+
+// declares reports whether key matches one of the fields, ignoring case like lookupKey
+func (s *Schema) declares(key string) bool {
+	for name := range s.Fields {
+		if strings.EqualFold(name, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkBounds reports a value outside Min and Max, what names the measured quantity
+func (s *Schema) checkBounds(value float64, what string, fail func(string, ...any)) {
+	prefix := "must be"
+	if what != "" {
+		prefix = what + " must be"
+	}
+	if s.Min != nil && value < *s.Min {
+		fail("%s at least %v, got %v", prefix, *s.Min, value)
+	}
+	if s.Max != nil && value > *s.Max {
+		fail("%s at most %v, got %v", prefix, *s.Max, value)
+	}
+}
+
+// allows reports whether value is one of the enum members
+func (s *Schema) allows(value any) bool {
+	number, isNumber := toNumber(value)
+	for _, member := range s.Enum {
+		if memberNumber, ok := toNumber(member); ok && isNumber {
+			if memberNumber == number {
+				return true
+			}
+			continue
+		}
+		if equalValues(member, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// equalValues compares config values by content, treating the array forms loaded with and
+// without Config.ConvertArrays alike
+func equalValues(a, b any) bool {
+	if items, ok := toArray(a); ok {
+		a = items
+	}
+	if items, ok := toArray(b); ok {
+		b = items
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// lookup returns the schema of the value at a dotted key path, matching field names like
+// lookupKey, or nil when the schema does not describe it
+func (s *Schema) lookup(path string) *Schema {
+	current := s
+	for _, key := range strings.Split(path, ".") {
+		next := current.Items
+		for name, field := range current.Fields {
+			if strings.EqualFold(name, key) {
+				next = field
+				break
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		current = next
+	}
+	return current
+}
+
+// lookupKey finds a key in a config object, falling back to a case-insensitive match
+// like Viper and mapstructure do
+func lookupKey(object map[string]any, name string) (any, bool) {
+	if value, ok := object[name]; ok {
+		return value, true
+	}
+	for key, value := range object {
+		if strings.EqualFold(key, name) {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+// toNumber converts a value of any numeric kind to float64
+func toNumber(value any) (float64, bool) {
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	default:
+		return 0, false
+	}
+}
+
+// toArray returns the items of the array forms produced by the loaders: slices, and maps whose
+// keys are 1 to n as loaded without Config.ConvertArrays
+func toArray(value any) ([]any, bool) {
+	if items, ok := value.([]any); ok {
+		return items, true
+	}
+	object, ok := toObject(value)
+	if !ok {
+		return nil, false
+	}
+	items := make([]any, len(object))
+	for i := range items {
+		item, ok := object[strconv.Itoa(i+1)]
+		if !ok {
+			return nil, false
+		}
+		items[i] = item
+	}
+	return items, true
+}
+
+// toObject returns the entries of the map types produced by the loaders
+func toObject(value any) (map[string]any, bool) {
+	switch v := value.(type) {
+	case map[string]any:
+		return v, true
+	case *OrderedMap:
+		return v.ToMap(), true
+	case map[any]any:
+		object := make(map[string]any, len(v))
+		for key, item := range v {
+			object[fmt.Sprint(key)] = item
+		}
+		return object, true
+	default:
+		return nil, false
+	}
+}
+
+// describeValue names the kind of a config value in violation messages
+func describeValue(value any) string {
+	switch value.(type) {
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case []any:
+		return "array"
+	case map[string]any, map[any]any, *OrderedMap:
+		return "object"
+	}
+	if _, ok := toNumber(value); ok {
+		return "number"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// formatEnum lists enum members for violation messages
+func formatEnum(enum []any) string {
+	members := make([]string, len(enum))
+	for i, member := range enum {
+		members[i] = fmt.Sprint(member)
+	}
+	return strings.Join(members, ", ")
+}
+
+// joinSchemaPath appends a key to a dotted path
+func joinSchemaPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// displaySchemaPath names the top level of the config when the path is empty
+func displaySchemaPath(path string) string {
+	if path == "" {
+		return "top level"
+	}
+	return path
+}
@@ -0,0 +1,252 @@
+package culebra
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type serverSchema struct {
+	Host string `culebra:"host,required"`
+	Port int    `culebra:"port,required,min=1,max=65535"`
+}
+
+type appSchema struct {
+	Level   string            `culebra:"level,enum=debug|info|warn"`
+	Server  serverSchema      `culebra:"server,required"`
+	Tags    []string          `culebra:"tags,max=2"`
+	Limits  map[string]int    `culebra:"limits"`
+	Retries int               `mapstructure:"retries" culebra:",min=0"`
+	Labels  map[string]string `culebra:"-"`
+}
+
+func TestSchemaOf(t *testing.T) {
+	schema, err := SchemaOf(&appSchema{})
+	if err != nil {
+		t.Fatalf("SchemaOf failed: %v", err)
+	}
+
+	port := schema.Fields["server"].Fields["port"]
+	if port.Type != TypeInteger || !port.Required || *port.Min != 1 || *port.Max != 65535 {
+		t.Errorf("Unexpected port schema: %+v", port)
+	}
+	if level := schema.Fields["level"]; !reflect.DeepEqual(level.Enum, []any{"debug", "info", "warn"}) {
+		t.Errorf("Unexpected enum: %v", level.Enum)
+	}
+	if _, ok := schema.Fields["retries"]; !ok {
+		t.Error("Expected mapstructure tag to name the field")
+	}
+	if _, ok := schema.Fields["Labels"]; ok {
+		t.Error("Expected skipped field to be left out")
+	}
+	if limits := schema.Fields["limits"]; limits.Type != TypeObject || limits.Items.Type != TypeInteger {
+		t.Errorf("Unexpected map schema: %+v", limits)
+	}
+
+	if _, err := SchemaOf("not a struct"); err == nil {
+		t.Error("Expected error for non-struct schema source")
+	}
+	if _, err := SchemaOf(struct {
+		Port int `culebra:"port,min=low"`
+	}{}); err == nil {
+		t.Error("Expected error for invalid tag option")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	data := map[string]any{
+		"level":   "trace",
+		"server":  map[string]any{"port": float64(70000)},
+		"tags":    []any{"a", "b", "c"},
+		"limits":  map[string]any{"cpu": 1.5},
+		"retries": float64(2),
+	}
+
+	err := Validate(data, appSchema{})
+	var errs ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("Expected ValidationErrors, got %v", err)
+	}
+
+	var paths []string
+	for _, e := range errs {
+		paths = append(paths, e.Path)
+	}
+	expected := []string{"level", "limits.cpu", "server.host", "server.port", "tags"}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Errorf("Expected violations at %v, got %v", expected, paths)
+	}
+
+	valid := map[string]any{"server": map[string]any{"host": "localhost", "port": float64(80)}}
+	if err := Validate(valid, appSchema{}); err != nil {
+		t.Errorf("Expected valid config, got %v", err)
+	}
+}
+
+func TestSchemaOfRecursiveType(t *testing.T) {
+	type Node struct {
+		Name     string  `culebra:"name"`
+		Children []*Node `culebra:"children"`
+	}
+	_, err := SchemaOf(Node{})
+	if err == nil || !strings.Contains(err.Error(), "recursive type") || !strings.Contains(err.Error(), "children") {
+		t.Errorf("Expected recursive type error naming the path, got %v", err)
+	}
+	if _, err := JSONSchemaOf(&Node{}); err == nil {
+		t.Error("Expected JSONSchemaOf to reject the recursive type")
+	}
+
+	type Pair struct {
+		Left, Right serverSchema
+	}
+	if _, err := SchemaOf(Pair{}); err != nil {
+		t.Errorf("Expected a struct used twice side by side to be accepted, got %v", err)
+	}
+}
+
+func TestLoadWithSchema(t *testing.T) {
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "test.lua")
+
+	configContent := `return {
+    level = "info",
+    server = {
+        host = "localhost",
+        port = 0,
+    },
+}
+`
+
+	if err := os.WriteFile(configFile, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	_, err := Load(Config{FilePath: configFile, Schema: appSchema{}})
+	var errs ValidationErrors
+	if !errors.As(err, &errs) || len(errs) != 1 {
+		t.Fatalf("Expected one validation error, got %v", err)
+	}
+	if errs[0].Path != "server.port" || errs[0].Line != 5 || errs[0].File != configFile {
+		t.Errorf("Expected violation at line 5, got %+v", errs[0])
+	}
+	if !strings.Contains(err.Error(), "at least 1") {
+		t.Errorf("Expected message with the bound, got %v", err)
+	}
+}
+
+func TestLoadWithSchemaEvaluatesOnce(t *testing.T) {
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "test.lua")
+
+	if err := os.WriteFile(configFile, []byte("culebra.warn(\"hey\")\nport = tick()\n"), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	calls, warnings := 0, 0
+	_, err := Load(Config{
+		FilePath:  configFile,
+		Globals:   map[string]any{"tick": func() int { calls++; return calls }},
+		Schema:    &Schema{Type: TypeObject, Fields: map[string]*Schema{"name": {Required: true}}},
+		OnWarning: func(Finding) { warnings++ },
+	})
+	if err == nil {
+		t.Fatal("Expected validation error")
+	}
+	if calls != 1 || warnings != 1 {
+		t.Errorf("Expected the config to be evaluated once, got %d calls and %d warnings", calls, warnings)
+	}
+}
+
+func TestLoadWithSchemaLocatesRequiredModules(t *testing.T) {
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "test.lua")
+	moduleFile := filepath.Join(tmpDir, "server.lua")
+
+	if err := os.WriteFile(moduleFile, []byte("local server = {}\nserver.host, server.port = \"localhost\", 0\nreturn server\n"), 0644); err != nil {
+		t.Fatalf("Failed to write module: %v", err)
+	}
+	configContent := `package.path = "` + filepath.Join(tmpDir, "?.lua") + `"
+return { level = "info", server = require("server") }
+`
+	if err := os.WriteFile(configFile, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	for _, load := range []func(Config) error{
+		func(cfg Config) error { _, err := Load(cfg); return err },
+		func(cfg Config) error { _, err := LoadDocument(cfg); return err },
+	} {
+		err := load(Config{FilePath: configFile, Schema: appSchema{}})
+		var errs ValidationErrors
+		if !errors.As(err, &errs) || len(errs) != 1 {
+			t.Fatalf("Expected one validation error, got %v", err)
+		}
+		if errs[0].Path != "server.port" || errs[0].Line != 2 || errs[0].File != moduleFile {
+			t.Errorf("Expected violation at %s:2, got %+v", moduleFile, errs[0])
+		}
+	}
+}
+
+func TestValidateEnumWithTables(t *testing.T) {
+	schema := &Schema{Type: TypeObject, Fields: map[string]*Schema{
+		"pair":  {Enum: []any{[]any{float64(1), float64(2)}, "none"}},
+		"other": {Enum: []any{map[string]any{"1": float64(3)}}},
+	}}
+
+	valid := map[string]any{
+		"pair":  map[string]any{"1": float64(1), "2": float64(2)},
+		"other": []any{float64(3)},
+	}
+	if err := Validate(valid, schema); err != nil {
+		t.Errorf("Expected table values matching table members to be valid, got %v", err)
+	}
+
+	invalid := map[string]any{"pair": map[string]any{"name": "x"}}
+	var errs ValidationErrors
+	if err := Validate(invalid, schema); !errors.As(err, &errs) || len(errs) != 1 || errs[0].Path != "pair" {
+		t.Errorf("Expected one violation at pair, got %v", err)
+	}
+
+	parsed, err := ParseJSONSchema([]byte(`{"type": "object", "properties": {"pair": {"enum": [[1, 2]]}}}`))
+	if err != nil {
+		t.Fatalf("ParseJSONSchema failed: %v", err)
+	}
+	if err := Validate(map[string]any{"pair": []any{float64(2), float64(1)}}, parsed); err == nil {
+		t.Error("Expected violation for a table value not in the enum")
+	}
+}
+
+func TestLoadWithSchemaDefaultArrays(t *testing.T) {
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "test.lua")
+
+	configContent := `
+culebra.schema{ hosts = culebra.list(culebra.string, { min = 1 }) }
+return {
+    level = "info",
+    server = { host = "localhost", port = 80 },
+    tags = { "a", "b" },
+    hosts = { "db1", "db2" },
+}
+`
+
+	if err := os.WriteFile(configFile, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	if _, err := Load(Config{FilePath: configFile, Schema: appSchema{}}); err != nil {
+		t.Errorf("Expected arrays loaded without ConvertArrays to validate, got %v", err)
+	}
+
+	invalid := filepath.Join(tmpDir, "invalid.lua")
+	if err := os.WriteFile(invalid, []byte(`return { server = { host = "h", port = 1 }, tags = { "a", "b", "c" } }`), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+	_, err := Load(Config{FilePath: invalid, Schema: appSchema{}})
+	if err == nil || !strings.Contains(err.Error(), "tags: length must be at most 2") {
+		t.Errorf("Expected array length violation, got %v", err)
+	}
+}
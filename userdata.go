@@ -0,0 +1,17 @@
+package culebra
+
+import "github.com/Fuabioo/culebra/internal"
+
+// Expose wraps a Go value, typically a struct pointer, so that passing it in Config.Globals
+// gives the config access to its exported fields and methods without copying it into tables.
+// Fields are read-only; assigning one raises a Lua error.
+//
+//	Globals: map[string]any{"host": culebra.Expose(hostInfo)} // Lua: workers = host.CPUCount() * 2
+func Expose(value any) any {
+	return internal.Userdata{Value: value}
+}
+
+// ExposeWritable is like Expose but lets the config assign exported fields of the value
+func ExposeWritable(value any) any {
+	return internal.Userdata{Value: value, Writable: true}
+}
@@ -0,0 +1,49 @@
+package culebra
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type hostInfo struct {
+	Hostname string
+	Cores    int
+}
+
+func (h *hostInfo) CPUCount() int {
+	return h.Cores
+}
+
+func TestLoadWithExposedGlobal(t *testing.T) {
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "test.lua")
+
+	configContent := `
+return {
+    workers = host.CPUCount() * 2,
+    name = host.Hostname,
+}
+`
+
+	if err := os.WriteFile(configFile, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	cfg := Config{
+		FilePath: configFile,
+		Globals:  map[string]any{"host": Expose(&hostInfo{Hostname: "web-1", Cores: 4})},
+	}
+
+	result, err := Load(cfg)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if result["workers"] != float64(8) {
+		t.Errorf("Expected workers=8, got %v", result["workers"])
+	}
+	if result["name"] != "web-1" {
+		t.Errorf("Expected name=web-1, got %v", result["name"])
+	}
+}